@@ -0,0 +1,148 @@
+package rfm69
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// air connects routers on fake radios like a shared channel, every frame
+// one of them transmits is received by all others
+type air struct {
+	mu      sync.Mutex
+	radios  map[byte]*fakeRadio
+	routers map[byte]*Router
+	watch   map[byte][]func(Data)
+
+	// hears, if set, tells whether frames from one node reach another
+	hears func(from, to byte) bool
+	// lost, if set, tells whether a frame is lost before anyone hears it
+	lost func(from byte, d Data) bool
+}
+
+// newAir starts a router for every node ID, they are closed when the test
+// ends
+func newAir(t *testing.T, ids ...byte) *air {
+	t.Helper()
+	return newAirWith(t, nil, ids...)
+}
+
+// newAirWith is newAir calling setup on every router before it runs
+func newAirWith(t *testing.T, setup func(*Router), ids ...byte) *air {
+	t.Helper()
+	a := &air{
+		radios:  make(map[byte]*fakeRadio),
+		routers: make(map[byte]*Router),
+		watch:   make(map[byte][]func(Data)),
+	}
+	for _, id := range ids {
+		id := id
+		f := newFakeRadio()
+		f.onTransmit = func(fifo []byte) { a.transmit(id, fifo) }
+		d, err := NewDevice(f, &RFMOptions{NodeID: id, NetworkID: 100, PollInterval: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.radios[id] = f
+		a.routers[id] = NewRouter(d)
+		if setup != nil {
+			setup(a.routers[id])
+		}
+	}
+	for _, r := range a.routers {
+		go r.Run()
+	}
+	t.Cleanup(func() {
+		for _, r := range a.routers {
+			r.Close()
+		}
+	})
+	return a
+}
+
+// router returns the router of node id
+func (a *air) router(id byte) *Router {
+	return a.routers[id]
+}
+
+// cut stops frames between the two nodes in both directions
+func (a *air) cut(x, y byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	prev := a.hears
+	a.hears = func(from, to byte) bool {
+		if from == x && to == y || from == y && to == x {
+			return false
+		}
+		return prev == nil || prev(from, to)
+	}
+}
+
+// lose drops the frames transmitted by nodes for which fn returns true
+func (a *air) lose(fn func(from byte, d Data) bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lost = fn
+}
+
+// onTransmit calls fn with every frame node id transmits
+func (a *air) onTransmit(id byte, fn func(Data)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.watch[id] = append(a.watch[id], fn)
+}
+
+// inject makes node id receive d as if it had been transmitted
+func (a *air) inject(id byte, d Data) {
+	ctl := byte(d.Kind) & kindMask
+	if d.RequestAck {
+		ctl |= 0x40
+	}
+	if d.SendAck {
+		ctl |= 0x80
+	}
+	fifo := append([]byte{byte(len(d.Data) + 3), d.ToAddress, d.FromAddress, ctl}, d.Data...)
+	a.radios[id].deliver(fifo)
+}
+
+func (a *air) transmit(from byte, fifo []byte) {
+	a.mu.Lock()
+	hears, lost, watch := a.hears, a.lost, a.watch[from]
+	a.mu.Unlock()
+	d := Data{
+		ToAddress:   fifo[1],
+		FromAddress: fifo[2],
+		RequestAck:  fifo[3]&0x40 != 0,
+		SendAck:     fifo[3]&0x80 != 0,
+		Kind:        Kind(fifo[3] & kindMask),
+		Data:        append([]byte(nil), fifo[4:]...),
+	}
+	for _, fn := range watch {
+		fn(d)
+	}
+	if lost != nil && lost(from, d) {
+		return
+	}
+	for id, f := range a.radios {
+		if id == from || hears != nil && !hears(from, id) {
+			continue
+		}
+		f.deliver(fifo)
+	}
+}
+
+// nothing checks that no frame arrives on c for a while
+func nothing(t *testing.T, c <-chan Data) {
+	t.Helper()
+	select {
+	case d := <-c:
+		t.Fatalf("unexpected frame %+v", d)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// collect returns a handler delivering frames to the returned channel
+func collect() (Handle, <-chan Data) {
+	c := make(chan Data, 64)
+	return func(d Data) { c <- d }, c
+}
@@ -0,0 +1,372 @@
+package rfm69
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// CapturedFrame is a received frame as the radio delivered it
+type CapturedFrame struct {
+	Time      time.Time
+	Frequency uint32 // Hz
+	Rssi      int
+	Fei       int    // frequency error in Hz
+	FeiValid  bool   // Fei was measured while the frame was on air
+	CRCOK     bool   // false for frames failing the CRC, see SetCapture
+	Raw       []byte // FIFO contents: length, to, from, control, payload
+}
+
+// Data parses the LowPowerLab header of the frame
+func (f *CapturedFrame) Data() (Data, error) {
+	if len(f.Raw) < 4 {
+		return Data{}, ErrFrameShort
+	}
+	return Data{
+		ToAddress:   f.Raw[1],
+		FromAddress: f.Raw[2],
+		SendAck:     f.Raw[3]&0x80 != 0,
+		RequestAck:  f.Raw[3]&0x40 != 0,
+		Kind:        Kind(f.Raw[3] & kindMask),
+		Data:        f.Raw[4:],
+		Rssi:        f.Rssi,
+		Fei:         f.Fei,
+		FeiValid:    f.FeiValid,
+	}, nil
+}
+
+// CaptureWriter receives every frame read from the FIFO
+type CaptureWriter interface {
+	WriteFrame(f *CapturedFrame) error
+}
+
+// fstep is the frequency synthesizer step in Hz
+const fstep = float64(FXOSC) / (1 << 19)
+
+// SetCapture hands every frame read from the FIFO to w, nil stops
+// capturing. While capturing the radio keeps frames failing the CRC so
+// they are captured too, they are not delivered
+func (r *Device) SetCapture(w CaptureWriter) error {
+	r.captureMu.Lock()
+	defer r.captureMu.Unlock()
+	prev := r.Capture
+	r.Capture = w
+	err := r.updateCRCAutoClear()
+	if err != nil {
+		r.Capture = prev
+	}
+	return err
+}
+
+// updateCRCAutoClear turns CRC auto clear off while capturing or trying
+// the keys of a grace window, both need the frames failing the CRC. The
+// caller holds captureMu
+func (r *Device) updateCRCAutoClear() error {
+	clear := byte(RF_PACKET1_CRCAUTOCLEAR_ON)
+	if r.Capture != nil || r.keyHunting {
+		clear = RF_PACKET1_CRCAUTOCLEAR_OFF
+	}
+	return r.readWriteReg(REG_PACKETCONFIG1, ^byte(RF_PACKET1_CRCAUTOCLEAR_OFF), clear)
+}
+
+func (r *Device) capture() CaptureWriter {
+	r.captureMu.Lock()
+	defer r.captureMu.Unlock()
+	return r.Capture
+}
+
+// captureFrame hands a received frame to the capture writer
+func (r *Device) captureFrame(w CaptureWriter, raw []byte, data *Data, crcOK bool) error {
+	freq, err := r.Frequency()
+	if err != nil {
+		return err
+	}
+	return w.WriteFrame(&CapturedFrame{
+		Time:      time.Now(),
+		Frequency: freq,
+		Rssi:      data.Rssi,
+		Fei:       data.Fei,
+		FeiValid:  data.FeiValid,
+		CRCOK:     crcOK,
+		Raw:       raw,
+	})
+}
+
+// measuredFEI returns the frequency error measured for the frame just
+// received, not valid unless the measurement was started at SyncAddress
+// and has completed
+func (r *Device) measuredFEI() (int, bool, error) {
+	started := r.feiStarted
+	r.feiStarted = false
+	if !started {
+		return 0, false, nil
+	}
+	afcfei, err := r.readReg(REG_AFCFEI)
+	if err != nil {
+		return 0, false, err
+	}
+	if afcfei&RF_AFCFEI_FEI_DONE == 0 {
+		return 0, false, nil
+	}
+	fei, err := r.readFEI()
+	return fei, err == nil, err
+}
+
+// readFEI returns the result of the last frequency error measurement
+func (r *Device) readFEI() (int, error) {
+	msb, err := r.readReg(REG_FEIMSB)
+	if err != nil {
+		return 0, err
+	}
+	lsb, err := r.readReg(REG_FEILSB)
+	if err != nil {
+		return 0, err
+	}
+	return int(float64(int16(uint16(msb)<<8|uint16(lsb))) * fstep), nil
+}
+
+// Captures are PCAPNG files with link type LINKTYPE_USER0. Every packet
+// starts with a pseudo header, all fields big endian:
+//
+//	version   uint8, captureVersion
+//	flags     uint8, bit 0 set if the CRC was ok, bit 1 if fei is valid
+//	rssi      int8, dBm
+//	reserved  uint8
+//	frequency uint32, Hz
+//	fei       int32, Hz
+//
+// followed by the FIFO contents. wireshark/rfm69.lua dissects it
+const (
+	LinkTypeUser0    = 147
+	captureVersion   = 1
+	captureHeaderLen = 12
+	captureCRCOK     = 0x01
+	captureFeiValid  = 0x02
+
+	blockSHB = 0x0A0D0D0A
+	blockIDB = 0x00000001
+	blockEPB = 0x00000006
+
+	byteOrderMagic = 0x1A2B3C4D
+	optTsresol     = 9
+)
+
+// PcapWriter writes captured frames as PCAPNG, it implements
+// CaptureWriter
+type PcapWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewPcapWriter writes the section and interface headers to w
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	p := &PcapWriter{w: w}
+
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1) // version 1.0
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+	err := p.block(blockSHB, shb)
+	if err != nil {
+		return nil, err
+	}
+
+	idb := make([]byte, 20)
+	binary.LittleEndian.PutUint16(idb[0:], LinkTypeUser0)
+	// snap length 0 is unlimited, timestamps in nanoseconds
+	binary.LittleEndian.PutUint16(idb[8:], optTsresol)
+	binary.LittleEndian.PutUint16(idb[10:], 1)
+	idb[12] = 9
+	err = p.block(blockIDB, idb)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// block writes a block, body must be padded to 32 bits
+func (p *PcapWriter) block(typ uint32, body []byte) error {
+	total := 12 + len(body)
+	b := make([]byte, total)
+	binary.LittleEndian.PutUint32(b, typ)
+	binary.LittleEndian.PutUint32(b[4:], uint32(total))
+	copy(b[8:], body)
+	binary.LittleEndian.PutUint32(b[total-4:], uint32(total))
+	_, err := p.w.Write(b)
+	return err
+}
+
+// WriteFrame implements CaptureWriter
+func (p *PcapWriter) WriteFrame(f *CapturedFrame) error {
+	pkt := make([]byte, captureHeaderLen, captureHeaderLen+len(f.Raw))
+	pkt[0] = captureVersion
+	if f.CRCOK {
+		pkt[1] |= captureCRCOK
+	}
+	if f.FeiValid {
+		pkt[1] |= captureFeiValid
+	}
+	rssi := f.Rssi
+	if rssi < -128 {
+		rssi = -128
+	}
+	pkt[2] = byte(int8(rssi))
+	binary.BigEndian.PutUint32(pkt[4:], f.Frequency)
+	binary.BigEndian.PutUint32(pkt[8:], uint32(int32(f.Fei)))
+	pkt = append(pkt, f.Raw...)
+
+	epb := make([]byte, 20, 20+len(pkt)+3)
+	ts := uint64(f.Time.UnixNano())
+	binary.LittleEndian.PutUint32(epb[4:], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(epb[8:], uint32(ts))
+	binary.LittleEndian.PutUint32(epb[12:], uint32(len(pkt)))
+	binary.LittleEndian.PutUint32(epb[16:], uint32(len(pkt)))
+	epb = append(epb, pkt...)
+	for len(epb)%4 != 0 {
+		epb = append(epb, 0)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.block(blockEPB, epb)
+}
+
+// PcapReader reads frames back from a capture
+type PcapReader struct {
+	r       io.Reader
+	order   binary.ByteOrder
+	tsUnits uint64 // per second
+	user0   bool
+}
+
+// NewPcapReader reads the section header from r
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	p := &PcapReader{r: r}
+	typ, _, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if typ != blockSHB {
+		return nil, errors.New("not a pcapng file")
+	}
+	return p, nil
+}
+
+// next reads a block, handling the byte order of section headers
+func (p *PcapReader) next() (uint32, []byte, error) {
+	var hdr [8]byte
+	_, err := io.ReadFull(p.r, hdr[:])
+	if err != nil {
+		return 0, nil, err
+	}
+	if binary.LittleEndian.Uint32(hdr[:]) == blockSHB {
+		var magic [4]byte
+		_, err = io.ReadFull(p.r, magic[:])
+		if err != nil {
+			return 0, nil, err
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic[:]) == byteOrderMagic:
+			p.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic[:]) == byteOrderMagic:
+			p.order = binary.BigEndian
+		default:
+			return 0, nil, errors.New("bad byte order magic")
+		}
+		p.tsUnits = 1e6
+		p.user0 = false
+		body, err := p.body(p.order.Uint32(hdr[4:]), 4)
+		return blockSHB, body, err
+	}
+	if p.order == nil {
+		return 0, nil, errors.New("not a pcapng file")
+	}
+	body, err := p.body(p.order.Uint32(hdr[4:]), 0)
+	return p.order.Uint32(hdr[:]), body, err
+}
+
+// body reads the rest of a block of total length, skip bytes of the body
+// were already consumed
+func (p *PcapReader) body(total uint32, skip int) ([]byte, error) {
+	if total < 12 || total%4 != 0 || total > 1<<20 {
+		return nil, fmt.Errorf("bad block length %d", total)
+	}
+	b := make([]byte, int(total)-8-skip)
+	_, err := io.ReadFull(p.r, b)
+	if err != nil {
+		return nil, err
+	}
+	return b[:len(b)-4], nil
+}
+
+// Next returns the next frame, io.EOF at the end of the capture. Packets
+// of other link types are skipped
+func (p *PcapReader) Next() (*CapturedFrame, error) {
+	for {
+		typ, body, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case blockIDB:
+			if len(body) < 8 {
+				return nil, errors.New("short interface block")
+			}
+			p.user0 = p.order.Uint16(body) == LinkTypeUser0
+			p.tsUnits = tsUnits(p.order, body[8:])
+		case blockEPB:
+			if !p.user0 || len(body) < 20 {
+				continue
+			}
+			n := p.order.Uint32(body[12:])
+			if int(n) > len(body)-20 {
+				return nil, errors.New("short packet block")
+			}
+			return p.frame(body, body[20:20+n])
+		}
+	}
+}
+
+func (p *PcapReader) frame(epb, pkt []byte) (*CapturedFrame, error) {
+	if len(pkt) < captureHeaderLen || pkt[0] != captureVersion {
+		return nil, errors.New("unknown capture header")
+	}
+	ts := uint64(p.order.Uint32(epb[4:]))<<32 | uint64(p.order.Uint32(epb[8:]))
+	sec, frac := ts/p.tsUnits, ts%p.tsUnits
+	return &CapturedFrame{
+		Time:      time.Unix(int64(sec), int64(frac*1e9/p.tsUnits)),
+		Frequency: binary.BigEndian.Uint32(pkt[4:]),
+		Rssi:      int(int8(pkt[2])),
+		Fei:       int(int32(binary.BigEndian.Uint32(pkt[8:]))),
+		FeiValid:  pkt[1]&captureFeiValid != 0,
+		CRCOK:     pkt[1]&captureCRCOK != 0,
+		Raw:       append([]byte(nil), pkt[captureHeaderLen:]...),
+	}, nil
+}
+
+// tsUnits reads if_tsresol from interface block options
+func tsUnits(order binary.ByteOrder, opts []byte) uint64 {
+	for len(opts) >= 4 {
+		code, n := order.Uint16(opts), int(order.Uint16(opts[2:]))
+		if code == 0 || 4+n > len(opts) {
+			break
+		}
+		if code == optTsresol && n >= 1 {
+			v := opts[4]
+			units := uint64(1)
+			for i := byte(0); i < v&0x7F; i++ {
+				if v&0x80 != 0 {
+					units *= 2
+				} else {
+					units *= 10
+				}
+			}
+			return units
+		}
+		opts = opts[4+(n+3)/4*4:]
+	}
+	return 1e6
+}
@@ -0,0 +1,255 @@
+package rfm69
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// frameLog is a CaptureWriter keeping the frames in memory
+type frameLog struct {
+	mu     sync.Mutex
+	frames []*CapturedFrame
+}
+
+func (l *frameLog) WriteFrame(f *CapturedFrame) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.frames = append(l.frames, f)
+	return nil
+}
+
+func (l *frameLog) wait(t *testing.T, n int) *CapturedFrame {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		l.mu.Lock()
+		if len(l.frames) >= n {
+			f := l.frames[n-1]
+			l.mu.Unlock()
+			return f
+		}
+		l.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("frame %d never captured", n)
+	return nil
+}
+
+func TestCaptureFEI(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	f.set(REG_FEIMSB, 0)
+	f.set(REG_FEILSB, 16) // 16 steps of 61 Hz
+
+	// sync seen while the packet is on air starts the measurement
+	f.sync()
+	deadline := time.Now().Add(time.Second)
+	for len(f.written(REG_AFCFEI)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	f.receive([]byte{1, 2, 0, 'a'}, true)
+	got := receive(t, d.Receive())
+	if !got.FeiValid || got.Fei != 976 {
+		t.Fatalf("fei %d valid %v, want %d", got.Fei, got.FeiValid, 976)
+	}
+
+	// sync seen together with PayloadReady is too late to measure
+	f.receive([]byte{1, 2, 0, 'b'}, true)
+	got = receive(t, d.Receive())
+	if got.FeiValid {
+		t.Fatalf("fei %d valid for a frame received before the measurement", got.Fei)
+	}
+}
+
+func TestCaptureCRCFailures(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	l := new(frameLog)
+	err := d.SetCapture(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := d.readReg(REG_PACKETCONFIG1); v&RF_PACKET1_CRCAUTOCLEAR_OFF == 0 {
+		t.Fatal("CRC auto clear still on while capturing")
+	}
+
+	f.receive([]byte{1, 2, 0, 'x'}, false)
+	if c := l.wait(t, 1); c.CRCOK {
+		t.Fatal("frame failing the CRC captured as ok")
+	}
+	nothing(t, d.Receive())
+
+	f.receive([]byte{1, 2, 0, 'y'}, true)
+	if c := l.wait(t, 2); !c.CRCOK || !bytes.Equal(c.Raw, []byte{4, 1, 2, 0, 'y'}) {
+		t.Fatalf("captured %+v", c)
+	}
+	if got := receive(t, d.Receive()); string(got.Data) != "y" {
+		t.Fatalf("received %q", got.Data)
+	}
+
+	err = d.SetCapture(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := d.readReg(REG_PACKETCONFIG1); v&RF_PACKET1_CRCAUTOCLEAR_OFF != 0 {
+		t.Fatal("CRC auto clear still off after capturing")
+	}
+}
+
+func TestCRCAutoClearCaptureAndKeys(t *testing.T) {
+	d, _ := newTestDevice(t, nil)
+	autoClear := func() bool {
+		v, _ := d.readReg(REG_PACKETCONFIG1)
+		return v&RF_PACKET1_CRCAUTOCLEAR_OFF == 0
+	}
+	for _, step := range []struct {
+		name string
+		set  func() error
+		want bool
+	}{
+		{"capture", func() error { return d.SetCapture(new(frameLog)) }, false},
+		{"grace window", func() error { return d.setKeyHunting(true) }, false},
+		{"capture stopped", func() error { return d.SetCapture(nil) }, false},
+		{"grace window over", func() error { return d.setKeyHunting(false) }, true},
+		{"grace window again", func() error { return d.setKeyHunting(true) }, false},
+		{"capture again", func() error { return d.SetCapture(new(frameLog)) }, false},
+		{"grace window over again", func() error { return d.setKeyHunting(false) }, false},
+		{"capture stopped again", func() error { return d.SetCapture(nil) }, true},
+	} {
+		err := step.set()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := autoClear(); got != step.want {
+			t.Fatalf("%s: CRC auto clear %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestPcapRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewPcapWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := []*CapturedFrame{
+		{Time: time.Unix(1700000000, 123456789), Frequency: 868000000, Rssi: -60, Fei: -1200, FeiValid: true, CRCOK: true, Raw: []byte{4, 1, 2, 0x40, 9}},
+		{Time: time.Unix(1700000001, 0), Frequency: 868000000, Rssi: -90, CRCOK: false, Raw: []byte{3, 255, 7, 0}},
+	}
+	for _, f := range in {
+		err = w.WriteFrame(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewPcapReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range in {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Time.Equal(want.Time) || got.Frequency != want.Frequency || got.Rssi != want.Rssi ||
+			got.Fei != want.Fei || got.FeiValid != want.FeiValid || got.CRCOK != want.CRCOK || !bytes.Equal(got.Raw, want.Raw) {
+			t.Fatalf("frame %d read back as %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestRetransmitKeepsSender(t *testing.T) {
+	e := NewEmulator(7)
+	var sent []Data
+	e.OnTransmit = func(d Data) { sent = append(sent, d) }
+	target := Retransmit(e)
+	for _, from := range []byte{3, 0} {
+		err := target.Replay(&CapturedFrame{CRCOK: true, Rssi: -40, Raw: []byte{4, 1, from, 0x40, 'x'}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, from := range []byte{3, 0} {
+		d := sent[i]
+		if d.FromAddress != from || d.ToAddress != 1 || !d.RequestAck || string(d.Data) != "x" || d.Rssi != 0 {
+			t.Fatalf("replayed %+v, want it from %d", d, from)
+		}
+	}
+
+	err := e.Transmit(&Data{ToAddress: 1, FromAddress: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := sent[2]; d.FromAddress != 7 {
+		t.Fatalf("plain transmit sent from %d, want 7", d.FromAddress)
+	}
+}
+
+// pcap writes frames to a capture and opens it for reading
+func pcap(t *testing.T, frames ...*CapturedFrame) *PcapReader {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewPcapWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		err = w.WriteFrame(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewPcapReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReplayIntoEmulator(t *testing.T) {
+	e := NewEmulator(1)
+	router := NewRouter(e)
+	h, got := collect()
+	router.Handle(2, h)
+	go router.Run()
+	defer router.Close()
+	start := time.Unix(1700000000, 0)
+	r := pcap(t,
+		&CapturedFrame{Time: start, Rssi: -50, CRCOK: true, Raw: []byte{4, 1, 2, 0, 'a'}},
+		&CapturedFrame{Time: start.Add(100 * time.Millisecond), CRCOK: false, Raw: []byte{4, 1, 2, 0, 'b'}},
+		&CapturedFrame{Time: start.Add(200 * time.Millisecond), Rssi: -70, CRCOK: true, Raw: []byte{4, 1, 2, 0, 'c'}},
+	)
+
+	began := time.Now()
+	n, err := Replay(context.Background(), r, e, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("replayed %d frames, want 2", n)
+	}
+	if d := time.Since(began); d < 50*time.Millisecond {
+		t.Fatalf("replayed in %v, 200ms at 4x takes 50ms", d)
+	}
+	for _, want := range []Data{{Rssi: -50, Data: []byte("a")}, {Rssi: -70, Data: []byte("c")}} {
+		if d := receive(t, got); d.Rssi != want.Rssi || string(d.Data) != string(want.Data) {
+			t.Fatalf("received %+v, want %+v", d, want)
+		}
+	}
+	nothing(t, got)
+}
+
+func TestReplayCanceled(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	r := pcap(t,
+		&CapturedFrame{Time: start, CRCOK: true, Raw: []byte{3, 1, 2, 0}},
+		&CapturedFrame{Time: start.Add(time.Hour), CRCOK: true, Raw: []byte{3, 1, 2, 0}},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	n, err := Replay(ctx, r, ReplayFunc(func(*CapturedFrame) error { return nil }), 1)
+	if n != 1 || err != context.DeadlineExceeded {
+		t.Fatalf("replayed %d frames, %v", n, err)
+	}
+}
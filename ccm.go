@@ -0,0 +1,222 @@
+package rfm69
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// ccm implements AES-CCM (RFC 3610) as a cipher.AEAD
+type ccm struct {
+	block     cipher.Block
+	tagSize   int
+	nonceSize int
+}
+
+// newCCM returns CCM with the given tag (4-16, even) and nonce (7-13) sizes
+func newCCM(block cipher.Block, tagSize, nonceSize int) (cipher.AEAD, error) {
+	if block.BlockSize() != aes.BlockSize {
+		return nil, errors.New("ccm: block size must be 16")
+	}
+	if tagSize < 4 || tagSize > 16 || tagSize%2 != 0 {
+		return nil, errors.New("ccm: invalid tag size")
+	}
+	if nonceSize < 7 || nonceSize > 13 {
+		return nil, errors.New("ccm: invalid nonce size")
+	}
+	return &ccm{block: block, tagSize: tagSize, nonceSize: nonceSize}, nil
+}
+
+func (c *ccm) NonceSize() int { return c.nonceSize }
+func (c *ccm) Overhead() int  { return c.tagSize }
+
+// maxLen is the largest message the length field of L bytes can hold
+func (c *ccm) maxLen() uint64 {
+	l := 15 - c.nonceSize
+	if l >= 8 {
+		return ^uint64(0)
+	}
+	return 1<<(8*uint(l)) - 1
+}
+
+// counterBlock returns A_i
+func (c *ccm) counterBlock(nonce []byte, i uint64) []byte {
+	a := make([]byte, aes.BlockSize)
+	a[0] = byte(15 - c.nonceSize - 1)
+	copy(a[1:], nonce)
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], i)
+	copy(a[1+c.nonceSize:], n[8-(15-c.nonceSize):])
+	return a
+}
+
+// mac computes the CBC-MAC T over B0, the associated data and plaintext
+func (c *ccm) mac(nonce, plaintext, ad []byte) []byte {
+	l := 15 - c.nonceSize
+	b0 := make([]byte, aes.BlockSize)
+	b0[0] = byte((c.tagSize-2)/2<<3 | (l - 1))
+	if len(ad) > 0 {
+		b0[0] |= 0x40
+	}
+	copy(b0[1:], nonce)
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(plaintext)))
+	copy(b0[1+c.nonceSize:], n[8-l:])
+
+	x := make([]byte, aes.BlockSize)
+	c.block.Encrypt(x, b0)
+	cbc := func(data []byte) {
+		for len(data) > 0 {
+			var blk [aes.BlockSize]byte
+			m := copy(blk[:], data)
+			data = data[m:]
+			xorBytes(x, blk[:])
+			c.block.Encrypt(x, x)
+		}
+	}
+	if len(ad) > 0 {
+		// associated data is always short here, 2 byte length encoding
+		cbc(append([]byte{byte(len(ad) >> 8), byte(len(ad))}, ad...))
+	}
+	cbc(plaintext)
+	return x[:c.tagSize]
+}
+
+// ctr xors src with the key stream starting at A_1
+func (c *ccm) ctr(dst, nonce, src []byte) {
+	stream := cipher.NewCTR(c.block, c.counterBlock(nonce, 1))
+	stream.XORKeyStream(dst, src)
+}
+
+func (c *ccm) Seal(dst, nonce, plaintext, ad []byte) []byte {
+	if len(nonce) != c.nonceSize {
+		panic("ccm: incorrect nonce length")
+	}
+	if uint64(len(plaintext)) > c.maxLen() || len(ad) >= 0xFF00 {
+		panic("ccm: message too large")
+	}
+	tag := c.mac(nonce, plaintext, ad)
+	s0 := make([]byte, aes.BlockSize)
+	c.block.Encrypt(s0, c.counterBlock(nonce, 0))
+	xorBytes(tag, s0)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+c.tagSize)
+	c.ctr(out, nonce, plaintext)
+	copy(out[len(plaintext):], tag)
+	return ret
+}
+
+func (c *ccm) Open(dst, nonce, ciphertext, ad []byte) ([]byte, error) {
+	if len(nonce) != c.nonceSize {
+		panic("ccm: incorrect nonce length")
+	}
+	if len(ciphertext) < c.tagSize || len(ad) >= 0xFF00 {
+		return nil, errors.New("ccm: message authentication failed")
+	}
+	ct, tag := ciphertext[:len(ciphertext)-c.tagSize], ciphertext[len(ciphertext)-c.tagSize:]
+	ret, out := sliceForAppend(dst, len(ct))
+	c.ctr(out, nonce, ct)
+
+	expected := c.mac(nonce, out, ad)
+	s0 := make([]byte, aes.BlockSize)
+	c.block.Encrypt(s0, c.counterBlock(nonce, 0))
+	xorBytes(expected, s0)
+	if subtle.ConstantTimeCompare(expected, tag) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errors.New("ccm: message authentication failed")
+	}
+	return ret, nil
+}
+
+// xorBytes sets dst[i] ^= src[i] for the length of dst
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the
+// appended tail
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
+
+// CCMFramer encrypts and authenticates payloads in software with AES-CCM.
+// The nonce is built from the sender node ID and its frame counter, which
+// also protects against replays. Unlike the radio's AES-ECB it is not
+// limited to 64 byte frames, payloads up to MaxLongDataLen minus 12 bytes
+// of counter and tag fit. Disable hardware encryption when using it
+type CCMFramer struct {
+	aead cipher.AEAD
+	*frameCounters
+}
+
+const ccmNonceLen = 13
+
+// NewCCMFramer creates a framer, store may be nil to keep counters in
+// memory only
+func NewCCMFramer(key Key, store CounterStore) (*CCMFramer, error) {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newCCM(block, tagLen, ccmNonceLen)
+	if err != nil {
+		return nil, err
+	}
+	return &CCMFramer{aead: aead, frameCounters: newFrameCounters(store)}, nil
+}
+
+// ccmNonce is the sender followed by the counter, zero padded in between
+func ccmNonce(from byte, counter []byte) []byte {
+	nonce := make([]byte, ccmNonceLen)
+	nonce[0] = from
+	copy(nonce[ccmNonceLen-counterLen:], counter)
+	return nonce
+}
+
+// Seal implements Framer
+func (c *CCMFramer) Seal(from, to, ctl byte, payload []byte) ([]byte, error) {
+	if len(payload)+counterLen+tagLen > MaxLongDataLen {
+		return nil, ErrPayloadSize
+	}
+	counter, err := c.nextCounter(from)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, counterLen, counterLen+len(payload)+tagLen)
+	binary.BigEndian.PutUint32(frame, counter)
+	ad := []byte{from, to, ctl}
+	return c.aead.Seal(frame, ccmNonce(from, frame), payload, ad), nil
+}
+
+// Open implements Framer
+func (c *CCMFramer) Open(from, to, ctl byte, frame []byte) ([]byte, error) {
+	if len(frame) < counterLen+tagLen {
+		return nil, ErrFrameShort
+	}
+	header := frame[:counterLen]
+	payload, err := c.aead.Open(nil, ccmNonce(from, header), frame[counterLen:], []byte{from, to, ctl})
+	if err != nil {
+		return nil, ErrAuth
+	}
+	err = c.accept(from, binary.BigEndian.Uint32(header))
+	if errors.Is(err, ErrReplay) {
+		return payload, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,93 @@
+package rfm69
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// vectors from RFC 3610 (packet vector #1) and NIST SP 800-38C (C.1-C.3)
+var ccmVectors = []struct {
+	name                 string
+	key, nonce, ad, text string
+	tagSize              int
+	sealed               string
+}{
+	{
+		name:    "RFC 3610 #1",
+		key:     "c0c1c2c3c4c5c6c7c8c9cacbcccdcecf",
+		nonce:   "00000003020100a0a1a2a3a4a5",
+		ad:      "0001020304050607",
+		text:    "08090a0b0c0d0e0f101112131415161718191a1b1c1d1e",
+		tagSize: 8,
+		sealed:  "588c979a61c663d2f066d0c2c0f989806d5f6b61dac38417e8d12cfdf926e0",
+	},
+	{
+		name:    "SP 800-38C C.1",
+		key:     "404142434445464748494a4b4c4d4e4f",
+		nonce:   "10111213141516",
+		ad:      "0001020304050607",
+		text:    "20212223",
+		tagSize: 4,
+		sealed:  "7162015b4dac255d",
+	},
+	{
+		name:    "SP 800-38C C.2",
+		key:     "404142434445464748494a4b4c4d4e4f",
+		nonce:   "1011121314151617",
+		ad:      "000102030405060708090a0b0c0d0e0f",
+		text:    "202122232425262728292a2b2c2d2e2f",
+		tagSize: 6,
+		sealed:  "d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd",
+	},
+	{
+		name:    "SP 800-38C C.3",
+		key:     "404142434445464748494a4b4c4d4e4f",
+		nonce:   "101112131415161718191a1b",
+		ad:      "000102030405060708090a0b0c0d0e0f10111213",
+		text:    "202122232425262728292a2b2c2d2e2f3031323334353637",
+		tagSize: 8,
+		sealed:  "e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5484392fbc1b09951",
+	},
+}
+
+func TestCCMVectors(t *testing.T) {
+	for _, v := range ccmVectors {
+		t.Run(v.name, func(t *testing.T) {
+			block, err := aes.NewCipher(unhex(t, v.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			nonce := unhex(t, v.nonce)
+			aead, err := newCCM(block, v.tagSize, len(nonce))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ad, text := unhex(t, v.ad), unhex(t, v.text)
+			sealed := aead.Seal(nil, nonce, text, ad)
+			if got := hex.EncodeToString(sealed); got != v.sealed {
+				t.Fatalf("sealed %s, want %s", got, v.sealed)
+			}
+			opened, err := aead.Open(nil, nonce, sealed, ad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(opened) != string(text) {
+				t.Fatalf("opened %x, want %x", opened, text)
+			}
+			sealed[0] ^= 1
+			if _, err := aead.Open(nil, nonce, sealed, ad); err == nil {
+				t.Fatal("tampered ciphertext opened")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Command rfm69-mqtt bridges an RFM69 module to an MQTT broker
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/charles-d-burton/rfm69-1/gateway/mqtt"
+	"github.com/charles-d-burton/rfm69-1/internal/options"
+)
+
+func main() {
+	var radio options.Radio
+	radio.Register(flag.CommandLine)
+	broker := flag.String("broker", "tcp://localhost:1883", "MQTT broker")
+	clientID := flag.String("client-id", "rfm69-gateway", "MQTT client ID")
+	prefix := flag.String("prefix", mqtt.DefaultPrefix, "topic prefix")
+	flag.Parse()
+
+	r, err := radio.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+
+	client, err := mqtt.Dial(*broker, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gw := mqtt.New(r, client, radio.Network)
+	gw.Prefix = *prefix
+	err = gw.Start(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r.Run()
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+func info(o *options, args []string) error {
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	version, err := r.RFM.Version()
+	if err != nil {
+		return err
+	}
+	regs, err := r.RFM.Registers()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version       0x%02x\n", version)
+	printConfig(regs, r.RFM.Address())
+	return nil
+}
+
+func dump(o *options, args []string) error {
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	regs, err := r.RFM.Registers()
+	if err != nil {
+		return err
+	}
+	for _, reg := range regs {
+		fmt.Printf("0x%02x  0x%02x  %08b\n", reg.Addr, reg.Value, reg.Value)
+	}
+	return nil
+}
+
+func send(o *options, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	to := fs.Uint("to", 0, "destination node")
+	ack := fs.Bool("ack", false, "request an ack")
+	isHex := fs.Bool("hex", false, "payload is hex encoded")
+	fs.Parse(args)
+	if *to > 255 {
+		return fmt.Errorf("node %d out of range", *to)
+	}
+	payload := []byte(strings.Join(fs.Args(), " "))
+	if *isHex {
+		var err error
+		payload, err = hex.DecodeString(string(payload))
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	go r.Run()
+	if *ack {
+		err = r.SendWithAck(byte(*to), payload)
+	} else {
+		err = r.Send(byte(*to), payload)
+	}
+	// Close returns once the queued frame is on air
+	cerr := r.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if *ack {
+		fmt.Printf("%d bytes sent to %d, acked\n", len(payload), *to)
+	} else {
+		fmt.Printf("%d bytes sent to %d\n", len(payload), *to)
+	}
+	return nil
+}
+
+func listen(o *options, args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	all := fs.Bool("all", false, "show frames addressed to other nodes too")
+	capture := fs.String("pcap", "", "also write every frame to this PCAPNG file")
+	fs.Parse(args)
+
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if *capture != "" {
+		f, err := os.Create(*capture)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w, err := rfm69.NewPcapWriter(f)
+		if err != nil {
+			return err
+		}
+		err = r.RFM.SetCapture(w)
+		if err != nil {
+			return err
+		}
+	}
+	r.Promiscuous = *all
+	r.HandleDefault(func(d rfm69.Data) {
+		fmt.Printf("%s %3d -> %3d  rssi %4d  kind %d  ack %-5v  % x  %q\n",
+			time.Now().Format("15:04:05.000"), d.FromAddress, d.ToAddress, d.Rssi, d.Kind, d.RequestAck, d.Data, d.Data)
+	})
+	r.Run()
+	return nil
+}
+
+func rssi(o *options, args []string) error {
+	fs := flag.NewFlagSet("rssi", flag.ExitOnError)
+	watch := fs.Bool("watch", false, "keep printing")
+	interval := fs.Duration("interval", 250*time.Millisecond, "watch interval")
+	fs.Parse(args)
+
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for {
+		v, err := r.RFM.RSSI()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%4d dBm %s\n", v, bar(v))
+		if !*watch {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
+
+func temp(o *options, args []string) error {
+	fs := flag.NewFlagSet("temp", flag.ExitOnError)
+	cal := fs.Int("cal", 0, "calibration offset in degrees")
+	fs.Parse(args)
+
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	t, err := r.RFM.Temperature(*cal)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d °C\n", t)
+	return nil
+}
+
+func scan(o *options, args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	from := fs.Uint("from", 0, "first frequency in Hz")
+	to := fs.Uint("to", 0, "last frequency in Hz")
+	step := fs.Uint("step", 100000, "step in Hz")
+	dwell := fs.Duration("dwell", 20*time.Millisecond, "time to settle on each frequency")
+	fs.Parse(args)
+	if *from == 0 || *to < *from || *step == 0 {
+		return errors.New("scan needs -from and -to with to >= from and a non-zero -step")
+	}
+
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	orig, err := r.RFM.Frequency()
+	if err != nil {
+		return err
+	}
+	defer r.RFM.SetFrequency(orig)
+	for f := *from; f <= *to; f += *step {
+		err = r.RFM.SetFrequency(uint32(f))
+		if err != nil {
+			return err
+		}
+		time.Sleep(*dwell)
+		v, err := r.RFM.RSSI()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%9.3f MHz %4d dBm %s\n", float64(f)/1e6, v, bar(v))
+	}
+	return nil
+}
+
+func replay(o *options, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "speed up factor, 0 for as fast as possible")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("replay needs a capture file")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pr, err := rfm69.NewPcapReader(f)
+	if err != nil {
+		return err
+	}
+
+	r, err := o.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	n, err := rfm69.Replay(context.Background(), pr, rfm69.Retransmit(r.Radio), *speed)
+	fmt.Printf("%d frames sent\n", n)
+	// let the last frame go out before closing
+	time.Sleep(100 * time.Millisecond)
+	return err
+}
+
+// bar draws RSSI from -120 dBm upwards
+func bar(rssi int) string {
+	n := (rssi + 120) / 2
+	if n < 0 {
+		n = 0
+	}
+	return strings.Repeat("#", n)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+var modes = map[byte]string{
+	rfm69.RF_OPMODE_SLEEP:       "sleep",
+	rfm69.RF_OPMODE_STANDBY:     "standby",
+	rfm69.RF_OPMODE_SYNTHESIZER: "synthesizer",
+	rfm69.RF_OPMODE_TRANSMITTER: "transmit",
+	rfm69.RF_OPMODE_RECEIVER:    "receive",
+}
+
+// printConfig decodes the main settings from a register dump, node is the
+// ID of this node, filtered in software rather than by RegNodeAdrs
+func printConfig(regs []rfm69.Register, node byte) {
+	reg := make(map[byte]byte, len(regs))
+	for _, r := range regs {
+		reg[r.Addr] = r.Value
+	}
+
+	mode, ok := modes[reg[rfm69.REG_OPMODE]&0x1C]
+	if !ok {
+		mode = "unknown"
+	}
+	fmt.Printf("mode          %s\n", mode)
+
+	modul := reg[rfm69.REG_DATAMODUL]
+	dataMode := "packet"
+	switch modul & 0x60 {
+	case rfm69.RF_DATAMODUL_DATAMODE_CONTINUOUS:
+		dataMode = "continuous with bit sync"
+	case rfm69.RF_DATAMODUL_DATAMODE_CONTINUOUSNOBSYNC:
+		dataMode = "continuous"
+	}
+	modulation := "FSK"
+	if modul&0x18 == rfm69.RF_DATAMODUL_MODULATIONTYPE_OOK {
+		modulation = "OOK"
+	}
+	fmt.Printf("data mode     %s, %s\n", dataMode, modulation)
+
+	frf := uint64(reg[rfm69.REG_FRFMSB])<<16 | uint64(reg[rfm69.REG_FRFMID])<<8 | uint64(reg[rfm69.REG_FRFLSB])
+	fmt.Printf("frequency     %.3f MHz\n", float64(frf*rfm69.FXOSC>>19)/1e6)
+
+	br := uint32(reg[rfm69.REG_BITRATEMSB])<<8 | uint32(reg[rfm69.REG_BITRATELSB])
+	if br != 0 {
+		fmt.Printf("bitrate       %d bps\n", rfm69.FXOSC/br)
+	}
+	fdev := uint64(reg[rfm69.REG_FDEVMSB]&0x3F)<<8 | uint64(reg[rfm69.REG_FDEVLSB])
+	fmt.Printf("deviation     %d Hz\n", fdev*rfm69.FXOSC>>19)
+
+	pa := reg[rfm69.REG_PALEVEL]
+	fmt.Printf("power level   %d (PA0 %v PA1 %v PA2 %v)\n", pa&0x1F, pa&0x80 != 0, pa&0x40 != 0, pa&0x20 != 0)
+	fmt.Printf("rssi thresh   %.1f dBm\n", -float64(reg[rfm69.REG_RSSITHRESH])/2)
+	fmt.Printf("network id    %d\n", reg[rfm69.REG_SYNCVALUE2])
+	fmt.Printf("node id       %d\n", node)
+
+	p1, p2 := reg[rfm69.REG_PACKETCONFIG1], reg[rfm69.REG_PACKETCONFIG2]
+	fmt.Printf("packet        variable %v, crc %v, address filter %d\n",
+		p1&rfm69.RF_PACKET1_FORMAT_VARIABLE != 0, p1&rfm69.RF_PACKET1_CRC_ON != 0, p1>>1&0x03)
+	fmt.Printf("aes           %v\n", p2&rfm69.RF_PACKET2_AES_ON != 0)
+}
@@ -0,0 +1,135 @@
+// Command rfm69 is a diagnostics tool for RFM69 modules
+//
+//	rfm69 [flags] info               silicon version and decoded configuration
+//	rfm69 [flags] dump               register dump
+//	rfm69 [flags] send -to N [-ack] [-hex] payload
+//	rfm69 [flags] listen [-all] [-pcap file]
+//	                                 print received packets with RSSI
+//	rfm69 [flags] rssi [-watch]      current RSSI
+//	rfm69 [flags] temp [-cal N]      die temperature
+//	rfm69 [flags] scan -from HZ -to HZ [-step HZ]
+//	rfm69 [flags] replay [-speed X] file.pcapng
+//
+// The flags before the command select the SPI bus, pins and radio settings
+// and are shared by all commands
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/host/v3"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+	shared "github.com/charles-d-burton/rfm69-1/internal/options"
+)
+
+// options shared by all commands
+type options struct {
+	bus       string
+	reset     string
+	irq       string
+	hcw       bool
+	node      byte
+	network   byte
+	frequency uint
+	bitrate   uint
+	key       string
+}
+
+type command struct {
+	usage string
+	run   func(o *options, args []string) error
+}
+
+var commands = map[string]command{
+	"info":   {"", info},
+	"dump":   {"", dump},
+	"send":   {"-to N [-ack] [-hex] payload", send},
+	"listen": {"[-all] [-pcap file]", listen},
+	"rssi":   {"[-watch] [-interval D]", rssi},
+	"temp":   {"[-cal N]", temp},
+	"scan":   {"-from HZ -to HZ [-step HZ] [-dwell D]", scan},
+	"replay": {"[-speed X] file.pcapng", replay},
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.bus, "bus", "", "SPI bus, the first one available if empty")
+	flag.StringVar(&o.reset, "reset", "GPIO22", "reset pin")
+	flag.StringVar(&o.irq, "irq", "GPIO25", "interrupt (DIO0) pin, polled if empty")
+	flag.BoolVar(&o.hcw, "hcw", true, "module is an RFM69HCW")
+	shared.ByteVar(flag.CommandLine, &o.node, "node", 1, "node ID")
+	shared.ByteVar(flag.CommandLine, &o.network, "network", 100, "network ID")
+	flag.UintVar(&o.frequency, "frequency", 0, "carrier frequency in Hz, module default if zero")
+	flag.UintVar(&o.bitrate, "bitrate", 0, "bit rate in bits/s, module default if zero")
+	flag.StringVar(&o.key, "key", "", "encryption key, 16 raw bytes, hex or base64")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	err := cmd.run(&o, flag.Args()[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [flags] command [command flags]\n\ncommands:\n", os.Args[0])
+	for _, name := range []string{"info", "dump", "send", "listen", "rssi", "temp", "scan", "replay"} {
+		fmt.Fprintf(os.Stderr, "  %-7s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	flag.PrintDefaults()
+}
+
+// open initializes the host drivers and the radio
+func (o *options) open() (*rfm69.Router, error) {
+	_, err := host.Init()
+	if err != nil {
+		return nil, err
+	}
+	opts := &rfm69.RFMOptions{
+		SPIBus:        o.bus,
+		NodeID:        o.node,
+		NetworkID:     o.network,
+		IsRfm69HCW:    o.hcw,
+		EncryptionKey: rfm69.KeyString(o.key),
+		ResetPin:      gpioreg.ByName(o.reset),
+	}
+	if o.irq != "" {
+		opts.IrqPin = gpioreg.ByName(o.irq)
+		if opts.IrqPin == nil {
+			return nil, fmt.Errorf("unknown pin %s", o.irq)
+		}
+	}
+	r, err := rfm69.Init(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.frequency != 0 {
+		err = r.RFM.SetFrequency(uint32(o.frequency))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.bitrate != 0 {
+		err = r.RFM.SetBitrate(uint32(o.bitrate))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
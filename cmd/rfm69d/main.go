@@ -0,0 +1,32 @@
+// Command rfm69d serves an RFM69 module over HTTP, see package
+// gateway/rest for the endpoints
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/charles-d-burton/rfm69-1/gateway/rest"
+	"github.com/charles-d-burton/rfm69-1/internal/options"
+)
+
+func main() {
+	var radio options.Radio
+	radio.Register(flag.CommandLine)
+	listen := flag.String("listen", ":8069", "HTTP listen address")
+	flag.Parse()
+
+	r, err := radio.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := rest.New(r)
+	go r.Run()
+
+	err = http.ListenAndServe(*listen, srv)
+	// log.Fatal skips deferred calls, release the radio first
+	r.Close()
+	log.Fatal(err)
+}
@@ -0,0 +1,132 @@
+package rfm69
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriptionBuffer is how many frames a subscriber may lag behind before
+// frames are dropped for it
+const subscriptionBuffer = 32
+
+// Conn is a connection to a radio. Router implements it for the local
+// module, package rpc for one attached to another machine
+type Conn interface {
+	Send(node byte, payload []byte) error
+	SendWithAck(node byte, payload []byte) error
+	Get(node byte, payload []byte) (Data, error)
+
+	// Listen delivers received frames from the given nodes, or all nodes
+	// if none are given, until ctx is done
+	Listen(ctx context.Context, nodes ...byte) (<-chan Data, error)
+
+	Config() (RadioConfig, error)
+	SetConfig(RadioConfig) error
+	Registers() ([]Register, error)
+}
+
+// ContextSender is implemented by connections whose sends take a context
+// for cancellation and tracing, such as Router
+type ContextSender interface {
+	SendContext(ctx context.Context, node byte, payload []byte) error
+	SendWithAckContext(ctx context.Context, node byte, payload []byte) error
+	GetContext(ctx context.Context, node byte, payload []byte) (Data, error)
+}
+
+var (
+	_ Conn          = (*Router)(nil)
+	_ ContextSender = (*Router)(nil)
+)
+
+// RadioConfig is the runtime configuration of a radio
+type RadioConfig struct {
+	NodeID     byte
+	NetworkID  byte
+	Frequency  uint32 // Hz, unchanged by SetConfig if zero
+	Bitrate    uint32 // bits/s, unchanged by SetConfig if zero
+	PowerLevel byte   // 0-31
+}
+
+// Config reads the current configuration of the radio
+func (r *Router) Config() (RadioConfig, error) {
+	c, ok := r.Radio.(Configurer)
+	if !ok {
+		return RadioConfig{}, ErrUnsupported
+	}
+	return c.RadioConfig()
+}
+
+// SetConfig reconfigures the radio
+func (r *Router) SetConfig(cfg RadioConfig) error {
+	c, ok := r.Radio.(Configurer)
+	if !ok {
+		return ErrUnsupported
+	}
+	return c.SetRadioConfig(cfg)
+}
+
+// Registers dumps the configuration registers of the radio
+func (r *Router) Registers() ([]Register, error) {
+	c, ok := r.Radio.(Configurer)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return c.Registers()
+}
+
+// Listen implements Conn, frames are delivered to listeners in addition
+// to the handlers
+func (r *Router) Listen(ctx context.Context, nodes ...byte) (<-chan Data, error) {
+	return r.subs.add(ctx, nodes), nil
+}
+
+type subscription struct {
+	nodes map[byte]bool // nil for all nodes
+	c     chan Data
+}
+
+// subscriptions fans received frames out to subscribers
+type subscriptions struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+func (s *subscriptions) add(ctx context.Context, nodes []byte) <-chan Data {
+	sub := &subscription{c: make(chan Data, subscriptionBuffer)}
+	if len(nodes) > 0 {
+		sub.nodes = make(map[byte]bool, len(nodes))
+		for _, n := range nodes {
+			sub.nodes[n] = true
+		}
+	}
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[*subscription]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subs, sub)
+		s.mu.Unlock()
+		close(sub.c)
+	}()
+	return sub.c
+}
+
+// publish hands d to every matching subscriber that keeps up
+func (s *subscriptions) publish(d Data) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		if sub.nodes != nil && !sub.nodes[d.FromAddress] {
+			continue
+		}
+		select {
+		case sub.c <- d:
+		default:
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package rfm69
+
+import (
+	"errors"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// Bit is a demodulated bit sampled on DCLK, 0 or 1
+type Bit uint8
+
+// Edge is a level change on DATA
+type Edge struct {
+	High bool
+	Time time.Time
+}
+
+// ContinuousOptions configures continuous mode
+type ContinuousOptions struct {
+	BitSync    bool   // recover DCLK on DIO1, otherwise DATA is raw
+	Modulation byte   // RF_DATAMODUL_MODULATIONTYPE_*
+	Bitrate    uint32 // bits/s, only used with BitSync
+}
+
+var (
+	errNoDclkPin  = errors.New("Dio1Pin (DCLK) not set")
+	errNoDataPin  = errors.New("Dio2Pin (DATA) not set")
+	errNotCont    = errors.New("device not in continuous mode")
+	errContActive = errors.New("device already in continuous mode")
+)
+
+// continuousRegs are changed by StartContinuous and restored by
+// StopContinuous
+var continuousRegs = []byte{REG_DATAMODUL, REG_BITRATEMSB, REG_BITRATELSB, REG_DIOMAPPING1, REG_DIOMAPPING2}
+
+// StartContinuous leaves packet mode, DIO1 becomes DCLK and DIO2 DATA.
+// The packet engine and FIFO are unused until StopContinuous is called
+func (r *Device) StartContinuous(options *ContinuousOptions) error {
+	if r.Config.Dio2Pin == nil {
+		return errNoDataPin
+	}
+	if options.BitSync && r.Config.Dio1Pin == nil {
+		return errNoDclkPin
+	}
+	r.contMu.Lock()
+	if r.continuous != nil {
+		r.contMu.Unlock()
+		return errContActive
+	}
+	// claimed before touching the radio so the receive loop keeps off it
+	r.continuous = options
+	r.contMu.Unlock()
+
+	err := r.startContinuous(options)
+	if err != nil {
+		r.contMu.Lock()
+		r.continuous = nil
+		r.contMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *Device) startContinuous(options *ContinuousOptions) error {
+	saved := make([]byte, len(continuousRegs))
+	for i, reg := range continuousRegs {
+		v, err := r.readReg(reg)
+		if err != nil {
+			return err
+		}
+		saved[i] = v
+	}
+	err := r.SetModeAndWait(RF_OPMODE_STANDBY)
+	if err != nil {
+		return err
+	}
+	dataMode := byte(RF_DATAMODUL_DATAMODE_CONTINUOUSNOBSYNC)
+	if options.BitSync {
+		dataMode = RF_DATAMODUL_DATAMODE_CONTINUOUS
+		err = r.SetBitrate(options.Bitrate)
+		if err != nil {
+			return err
+		}
+	}
+	err = r.writeReg(REG_DATAMODUL, dataMode|options.Modulation|RF_DATAMODUL_MODULATIONSHAPING_00)
+	if err != nil {
+		return err
+	}
+	err = r.writeReg(REG_DIOMAPPING1, RF_DIOMAPPING1_DIO0_00|RF_DIOMAPPING1_DIO1_00|RF_DIOMAPPING1_DIO2_01)
+	if err != nil {
+		return err
+	}
+	r.contMu.Lock()
+	r.contSaved = saved
+	r.contQuit = make(chan bool)
+	r.contMu.Unlock()
+	r.borrowPins()
+	return nil
+}
+
+// StopContinuous ends any bit or edge stream and returns to packet mode
+// with the modulation, bitrate and DIO mapping in use before
+// StartContinuous
+func (r *Device) StopContinuous() error {
+	r.contMu.Lock()
+	if r.continuous == nil || r.contQuit == nil {
+		r.contMu.Unlock()
+		return errNotCont
+	}
+	quit, saved := r.contQuit, r.contSaved
+	r.contQuit = nil
+	r.contMu.Unlock()
+
+	close(quit)
+	r.streams.Wait()
+	err := r.SetModeAndWait(RF_OPMODE_STANDBY)
+	if err != nil {
+		return err
+	}
+	for i, reg := range continuousRegs {
+		err = r.writeReg(reg, saved[i])
+		if err != nil {
+			return err
+		}
+	}
+	r.returnPins()
+	r.contMu.Lock()
+	r.continuous = nil
+	r.contMu.Unlock()
+	return r.SetMode(RF_OPMODE_RECEIVER)
+}
+
+// inContinuous tells whether continuous mode owns the radio
+func (r *Device) inContinuous() bool {
+	r.contMu.Lock()
+	defer r.contMu.Unlock()
+	return r.continuous != nil
+}
+
+// stream returns the active options and the channel closed by
+// StopContinuous, nil while not in continuous mode
+func (r *Device) stream() (*ContinuousOptions, chan bool) {
+	r.contMu.Lock()
+	defer r.contMu.Unlock()
+	if r.contQuit == nil {
+		return nil, nil
+	}
+	return r.continuous, r.contQuit
+}
+
+// borrowPins takes DIO1 and DIO2 over from their IRQ watchers, each stops
+// waiting for edges before it hands the pin over
+func (r *Device) borrowPins() {
+	r.pinReturn = make(chan struct{})
+	for i, pin := range []gpio.PinIn{r.Config.Dio1Pin, r.Config.Dio2Pin} {
+		if pin == nil {
+			continue
+		}
+		select {
+		case r.pinLend[i] <- r.pinReturn:
+		case <-r.stop:
+		}
+	}
+}
+
+// returnPins hands DIO1 and DIO2 back to their IRQ watchers
+func (r *Device) returnPins() {
+	close(r.pinReturn)
+}
+
+// ReceiveBits starts the receiver and streams DATA sampled on each rising
+// DCLK edge, requires BitSync. The channel is closed by StopContinuous
+func (r *Device) ReceiveBits() (<-chan Bit, error) {
+	options, quit := r.stream()
+	if options == nil {
+		return nil, errNotCont
+	}
+	if !options.BitSync {
+		return nil, errors.New("bit stream requires BitSync")
+	}
+	dclk, data := r.Config.Dio1Pin, r.Config.Dio2Pin
+	err := dclk.In(gpio.Float, gpio.RisingEdge)
+	if err != nil {
+		return nil, err
+	}
+	err = data.In(gpio.Float, gpio.NoEdge)
+	if err != nil {
+		return nil, err
+	}
+	err = r.SetMode(RF_OPMODE_RECEIVER)
+	if err != nil {
+		return nil, err
+	}
+	bits := make(chan Bit, 1024)
+	r.streams.Add(1)
+	go func() {
+		defer r.streams.Done()
+		defer close(bits)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+			}
+			if !dclk.WaitForEdge(100 * time.Millisecond) {
+				continue
+			}
+			var b Bit
+			if data.Read() == gpio.High {
+				b = 1
+			}
+			select {
+			case bits <- b:
+			default: // reader too slow, drop
+			}
+		}
+	}()
+	return bits, nil
+}
+
+// ReceiveEdges starts the receiver and streams timestamped level changes of
+// DATA. The channel is closed by StopContinuous
+func (r *Device) ReceiveEdges() (<-chan Edge, error) {
+	options, quit := r.stream()
+	if options == nil {
+		return nil, errNotCont
+	}
+	data := r.Config.Dio2Pin
+	err := data.In(gpio.Float, gpio.BothEdges)
+	if err != nil {
+		return nil, err
+	}
+	err = r.SetMode(RF_OPMODE_RECEIVER)
+	if err != nil {
+		return nil, err
+	}
+	edges := make(chan Edge, 1024)
+	r.streams.Add(1)
+	go func() {
+		defer r.streams.Done()
+		defer close(edges)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+			}
+			if !data.WaitForEdge(100 * time.Millisecond) {
+				continue
+			}
+			e := Edge{High: data.Read() == gpio.High, Time: time.Now()}
+			select {
+			case edges <- e:
+			default: // reader too slow, drop
+			}
+		}
+	}()
+	return edges, nil
+}
+
+// TransmitBits sends bits clocked by DCLK, requires BitSync. DATA is
+// changed on the falling edge so it is stable when the radio samples it
+func (r *Device) TransmitBits(bits []Bit) (err error) {
+	options, _ := r.stream()
+	if options == nil {
+		return errNotCont
+	}
+	if !options.BitSync {
+		return errors.New("bit stream requires BitSync")
+	}
+	dclk, data := r.Config.Dio1Pin, r.Config.Dio2Pin
+	err = dclk.In(gpio.Float, gpio.FallingEdge)
+	if err != nil {
+		return err
+	}
+	err = data.Out(gpio.Low)
+	if err != nil {
+		return err
+	}
+	err = r.SetMode(RF_OPMODE_TRANSMITTER)
+	if err != nil {
+		return err
+	}
+	defer r.endTransmit(data, &err)
+	for _, b := range bits {
+		if !dclk.WaitForEdge(time.Second) {
+			return errors.New("timeout waiting for DCLK")
+		}
+		err = data.Out(gpio.Level(b != 0))
+		if err != nil {
+			return err
+		}
+	}
+	// the radio samples the last bit on the next rising edge
+	dclk.WaitForEdge(time.Second)
+	return nil
+}
+
+// TransmitPulses keys DATA directly with host timing, typically used with
+// OOK and without BitSync
+func (r *Device) TransmitPulses(pulses []Pulse) (err error) {
+	if options, _ := r.stream(); options == nil {
+		return errNotCont
+	}
+	data := r.Config.Dio2Pin
+	err = data.Out(gpio.Low)
+	if err != nil {
+		return err
+	}
+	err = r.SetMode(RF_OPMODE_TRANSMITTER)
+	if err != nil {
+		return err
+	}
+	defer r.endTransmit(data, &err)
+	deadline := time.Now()
+	for _, p := range pulses {
+		err = data.Out(gpio.Level(p.High))
+		if err != nil {
+			return err
+		}
+		deadline = deadline.Add(p.Width)
+		// busy wait, sleeping is far too coarse for pulse timing
+		for time.Now().Before(deadline) {
+		}
+	}
+	return nil
+}
+
+// endTransmit drives DATA low and leaves TX however the transmission
+// ended, *err keeps the first error
+func (r *Device) endTransmit(data gpio.PinOut, err *error) {
+	lowErr := data.Out(gpio.Low)
+	modeErr := r.SetMode(RF_OPMODE_STANDBY)
+	if *err == nil {
+		*err = lowErr
+	}
+	if *err == nil {
+		*err = modeErr
+	}
+}
+
+// EdgesToPulses converts a run of edges into pulses so they can be fed to
+// an OOKDecoder, the level after the last edge has no known width
+func EdgesToPulses(edges []Edge) []Pulse {
+	var pulses []Pulse
+	for i := 0; i+1 < len(edges); i++ {
+		pulses = append(pulses, Pulse{High: edges[i].High, Width: edges[i+1].Time.Sub(edges[i].Time)})
+	}
+	return pulses
+}
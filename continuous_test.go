@@ -0,0 +1,131 @@
+package rfm69
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpiotest"
+)
+
+func newContinuousDevice(t *testing.T) (*Device, *fakeRadio, *gpiotest.Pin) {
+	t.Helper()
+	dclk := &gpiotest.Pin{N: "dio1", EdgesChan: make(chan gpio.Level, 16)}
+	data := &gpiotest.Pin{N: "dio2", EdgesChan: make(chan gpio.Level, 16)}
+	d, f := newTestDevice(t, &RFMOptions{NodeID: 1, Dio1Pin: dclk, Dio2Pin: data})
+	return d, f, data
+}
+
+func TestContinuousRestoresPacketMode(t *testing.T) {
+	d, f, _ := newContinuousDevice(t)
+	err := d.SetBitrate(55555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.set(REG_DATAMODUL, RF_DATAMODUL_DATAMODE_PACKET|RF_DATAMODUL_MODULATIONTYPE_OOK)
+	before := make([]byte, len(continuousRegs))
+	for i, reg := range continuousRegs {
+		before[i], _ = d.readReg(reg)
+	}
+
+	err = d.StartContinuous(&ContinuousOptions{BitSync: true, Bitrate: 4800})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StartContinuous(&ContinuousOptions{}); err != errContActive {
+		t.Fatalf("second start: %v", err)
+	}
+	err = d.StopContinuous()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StopContinuous(); err != errNotCont {
+		t.Fatalf("second stop: %v", err)
+	}
+	for i, reg := range continuousRegs[:3] {
+		if v, _ := d.readReg(reg); v != before[i] {
+			t.Errorf("register %#x is %#x after StopContinuous, want %#x", reg, v, before[i])
+		}
+	}
+}
+
+func TestContinuousEdgesOwnDataPin(t *testing.T) {
+	d, _, data := newContinuousDevice(t)
+	err := d.StartContinuous(&ContinuousOptions{Modulation: RF_DATAMODUL_MODULATIONTYPE_OOK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges, err := d.ReceiveEdges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		data.EdgesChan <- gpio.Level(i%2 == 0)
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case e := <-edges:
+			if e.High != (i%2 == 0) {
+				t.Fatalf("edge %d high %v", i, e.High)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("edge %d taken by the IRQ watcher", i)
+		}
+	}
+	err = d.StopContinuous()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-edges; ok {
+		t.Fatal("edge stream still open after StopContinuous")
+	}
+}
+
+// failingPin fails the n-th Out
+type failingPin struct {
+	*gpiotest.Pin
+	n int
+}
+
+func (p *failingPin) Out(l gpio.Level) error {
+	p.n--
+	if p.n == 0 {
+		return errors.New("pin gone")
+	}
+	return p.Pin.Out(l)
+}
+
+func TestTransmitStopsOnError(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		bitSync  bool
+		transmit func(*Device) error
+	}{
+		{"pulses", false, func(d *Device) error {
+			return d.TransmitPulses([]Pulse{{High: true, Width: time.Microsecond}, {Width: time.Microsecond}})
+		}},
+		{"bits", true, func(d *Device) error { return d.TransmitBits([]Bit{1, 0}) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dclk := &gpiotest.Pin{N: "dio1", EdgesChan: make(chan gpio.Level, 16)}
+			// the second level written, after the radio switched to TX
+			data := &failingPin{Pin: &gpiotest.Pin{N: "dio2", EdgesChan: make(chan gpio.Level, 16)}, n: 2}
+			d, _ := newTestDevice(t, &RFMOptions{NodeID: 1, Dio1Pin: dclk, Dio2Pin: data})
+			err := d.StartContinuous(&ContinuousOptions{BitSync: tc.bitSync, Bitrate: 4800, Modulation: RF_DATAMODUL_MODULATIONTYPE_OOK})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dclk.EdgesChan <- gpio.Low
+			if err := tc.transmit(d); err == nil {
+				t.Fatal("transmitted with a failing pin")
+			}
+			if m := d.currentMode(); m != RF_OPMODE_STANDBY {
+				t.Fatalf("mode %#x after the failure, want standby", m)
+			}
+			if l := data.Read(); l != gpio.Low {
+				t.Fatal("DATA left high")
+			}
+		})
+	}
+}
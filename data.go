@@ -1,5 +1,23 @@
 package rfm69
 
+// Kind is the frame type carried in the low nibble of the control byte.
+// Plain data is zero so LowPowerLab nodes interoperate
+type Kind byte
+
+// Frame kinds
+const (
+	KindData Kind = iota
+	KindHello
+	KindGroup // ToAddress carries a multicast group ID
+	KindMesh
+	KindRelay // re-transmitted by a relay, see Relay
+)
+
+const kindMask = 0x0F
+
+// BroadcastAddress reaches every node of the network
+const BroadcastAddress = 255
+
 // Data is the data structure for the protocol
 type Data struct {
 	ToAddress   byte
@@ -7,7 +25,13 @@ type Data struct {
 	Data        []byte
 	RequestAck  bool
 	SendAck     bool
+	Kind        Kind
 	Rssi        int
+	Fei         int  // frequency error in Hz
+	FeiValid    bool // Fei was measured while the frame was on air
+	// Replayed frames are transmitted under FromAddress, even 0, instead
+	// of the radio's address. Only set it to replay captures
+	Replayed bool
 }
 
 // ToAck creates an ack
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
+	"sync"
 	"time"
 
 	"periph.io/x/conn/v3/gpio"
@@ -19,37 +22,109 @@ const (
 // OnReceiveHandler is the receive callback
 type OnReceiveHandler func(*Data)
 
+// OnLowBatteryHandler is the low battery callback
+type OnLowBatteryHandler func()
+
+// OnOOKHandler is the OOK code callback
+type OnOOKHandler func(OOKCode)
+
+// OnIRQHandler is an interrupt callback
+type OnIRQHandler func()
+
 // Device RFM69 Device
 type Device struct {
 	spiDevice  spi.Conn
 	mode       byte
+	modeMu     sync.Mutex // mode and dioMapping, changed by the loop, continuous mode and SetDIOMapping
 	Config     *RFMOptions
 	powerLevel byte
 	tx         chan *Data
+	rx         chan Data
 	quit       chan bool
+	stop       chan struct{} // closed by Close to end the IRQ watchers
+	watchers   sync.WaitGroup
+	lowBatMu   sync.Mutex // lowBatOn and lowBat, the threshold is set while running
+	lowBatOn   bool
+	lowBat     bool
+	ook        *OOKOptions
+	ookSaved   []byte     // ookRegs before SetOOK
+	ookMu      sync.Mutex // ook, set by SetOOK and SetFSK while the loop receives
+	continuous *ContinuousOptions
+	contQuit   chan bool
+	contSaved  []byte // continuousRegs before StartContinuous
+	contMu     sync.Mutex
+	streams    sync.WaitGroup        // ReceiveBits and ReceiveEdges
+	pinLend    [2]chan chan struct{} // DIO1/DIO2 handover to continuous mode
+	pinReturn  chan struct{}         // closed to give DIO1/DIO2 back
+	dioMapping map[byte]*[6]byte
+	syncSeen   bool
+	feiStarted bool            // FEI measurement started at SyncAddress
+	rxTimeouts *RxTimeoutStats // allocated for 64 bit atomic alignment
+	aesKey     Key
+	aesOn      bool
+	aesMu      sync.Mutex // aesKey and aesOn, loaded by Encrypt and the key schedule
+	metrics    metricsRef
 	OnReceive  OnReceiveHandler
+
+	// OnLowBattery is called once each time the supply drops below the
+	// threshold set with SetLowBatteryThreshold
+	OnLowBattery OnLowBatteryHandler
+
+	// OOKDecoders are tried in order on every frame received in OOK mode,
+	// OnOOK is called with the first code decoded
+	OOKDecoders []OOKDecoder
+	OnOOK       OnOOKHandler
+
+	// OnSyncAddress and OnTimeout are called from the receive loop when
+	// the corresponding flag is set, route the signal to a wired DIO pin
+	// with SetDIOMapping to be notified without delay
+	OnSyncAddress OnIRQHandler
+	OnTimeout     OnIRQHandler
+
+	// Capture, if set, receives every frame read from the FIFO with its
+	// RSSI, FEI and frequency, e.g. a PcapWriter. Set it with SetCapture
+	// while the device is running
+	Capture    CaptureWriter
+	keyHunting bool       // a grace window is open, see updateKeys
+	captureMu  sync.Mutex // Capture and keyHunting, both keep CRC failures
 }
 
 // Global settings
 const (
 	CsmaLimit  = -80
 	MaxDataLen = 66
+	aesBlock   = 16       // the radio encrypts 16 byte blocks
+	FXOSC      = 32000000 // crystal oscillator frequency in Hz
+	rxBuffer   = 16
+	fifoSize   = 66
+
+	// MaxLongDataLen is the largest payload without hardware AES, frames
+	// longer than the FIFO are streamed through it
+	MaxLongDataLen = 255 - 3
+	// maxAESDataLen is the largest payload the radio encrypts, 64 bytes
+	// after the length byte
+	maxAESDataLen = 64 - 3
+
+	// streamGap is how long a frame being streamed may stall before it is
+	// given up, e.g. dropped by the radio for a bad CRC
+	streamGap = 100 * time.Millisecond
+
+	// csmaTimeout is how long a busy channel delays sending
+	csmaTimeout = time.Second
+
+	// DefaultPollInterval is used when no IrqPin is wired and
+	// RFMOptions.PollInterval is zero
+	DefaultPollInterval = 10 * time.Millisecond
 )
 
 // NewDevice creates a new device
 func NewDevice(spiPort spi.Port, options *RFMOptions) (*Device, error) {
-	if options.ResetPin == nil {
-		fmt.Printf("resetpin not set")
-	}
-
 	if options.IrqPin != nil {
-        fmt.Println("pulling up IRQ")
 		if err := options.IrqPin.In(gpio.PullUp, gpio.FallingEdge); err != nil {
 			return nil, err
 		}
 	}
 
-    fmt.Println("connecting to SPI interface")
 	spiDev, err := spiPort.Connect(10*physic.MegaHertz, spi.Mode0, 8)
 	if err != nil {
 		return nil, err
@@ -60,7 +135,12 @@ func NewDevice(spiPort spi.Port, options *RFMOptions) (*Device, error) {
 		Config:     options,
 		powerLevel: 31,
 		tx:         make(chan *Data, 5),
+		rx:         make(chan Data, rxBuffer),
 		quit:       make(chan bool),
+		stop:       make(chan struct{}),
+		pinLend:    [2]chan chan struct{}{make(chan chan struct{}), make(chan chan struct{})},
+		dioMapping: defaultDIOMapping(options.Dio3Pin != nil),
+		rxTimeouts: new(RxTimeoutStats),
 	}
 
 	err = ret.setup()
@@ -79,14 +159,110 @@ func (r *Device) Close() error {
 	r.quit <- true
 	<-r.quit
 
+	// stop the IRQ watchers, halting the pins ends a pending WaitForEdge
+	close(r.stop)
+	if r.Config.IrqPin != nil {
+		r.Config.IrqPin.Halt()
+	}
+	for _, pin := range r.dioPins() {
+		pin.Halt()
+	}
+	r.watchers.Wait()
+
 	return nil
 }
 
+// WaitForIRQ feeds DIO0 edges into irq, false when none arrived within the
+// wait period. Edges on the other wired DIO pins are fed in as well. Without
+// IrqPin the interrupt flags are polled over SPI instead. The watchers run
+// until Close
 func (r *Device) WaitForIRQ(irq chan<- bool) {
+	pins := []gpio.PinIn{r.Config.Dio1Pin, r.Config.Dio2Pin, r.Config.Dio3Pin, r.Config.Dio4Pin, r.Config.Dio5Pin}
+	for i, pin := range pins {
+		if pin == nil {
+			continue
+		}
+		var lend chan chan struct{}
+		if i < len(r.pinLend) {
+			lend = r.pinLend[i]
+		}
+		r.watchDIO(pin, irq, lend)
+	}
+	if r.Config.IrqPin == nil {
+		r.pollIRQ(irq)
+		return
+	}
+	r.watchers.Add(1)
+	go func() {
+		defer r.watchers.Done()
+		for {
+			edge := r.Config.IrqPin.WaitForEdge(100 * time.Millisecond)
+			if !r.signal(irq, edge) {
+				return
+			}
+		}
+	}()
+}
+
+// signal sends v to irq, false once the device is closed
+func (r *Device) signal(irq chan<- bool, v bool) bool {
+	select {
+	case irq <- v:
+		return true
+	case <-r.stop:
+		return false
+	}
+}
+
+// pollIRQ reads RegIrqFlags1/2 every PollInterval and feeds irq like a
+// DIO0 edge would, true when a flag gets set and false every 100ms
+// otherwise. Flags staying set, like SyncAddressMatch during a long
+// packet, do not hold off the idle checks
+func (r *Device) pollIRQ(irq chan<- bool) {
+	interval := r.Config.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	r.watchers.Add(1)
 	go func() {
-		defer close(irq)
+		defer r.watchers.Done()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		idle := time.Now()
+		var prev uint16
 		for {
-			irq <- r.Config.IrqPin.WaitForEdge(100 * time.Millisecond)
+			select {
+			case <-tick.C:
+			case <-r.stop:
+				return
+			}
+			if r.inContinuous() {
+				continue
+			}
+			flags1, err := r.readReg(REG_IRQFLAGS1)
+			if err != nil {
+				continue
+			}
+			flags2, err := r.readReg(REG_IRQFLAGS2)
+			if err != nil {
+				continue
+			}
+			pending := uint16(flags1&(RF_IRQFLAGS1_TIMEOUT|RF_IRQFLAGS1_SYNCADDRESSMATCH))<<8 |
+				uint16(flags2&(RF_IRQFLAGS2_PAYLOADREADY|RF_IRQFLAGS2_PACKETSENT))
+			rising := pending &^ prev
+			prev = pending
+			if rising != 0 {
+				if !r.signal(irq, true) {
+					return
+				}
+				continue
+			}
+			if time.Since(idle) >= 100*time.Millisecond {
+				idle = time.Now()
+				if !r.signal(irq, false) {
+					return
+				}
+			}
 		}
 	}()
 }
@@ -95,7 +271,6 @@ func (r *Device) writeReg(addr, data byte) error {
 	tx := make([]byte, 2)
 	tx[0] = addr | 0x80
 	tx[1] = data
-	fmt.Printf("write %x: %x\n", addr, data)
 	length := len(tx)
 	rx := make([]byte, length)
 	err := r.spiDevice.Tx(tx, rx)
@@ -119,7 +294,6 @@ func (r *Device) readReg(addr byte) (byte, error) {
 }
 
 func (r *Device) setup() error {
-    fmt.Println("running initialization")
 	Config := [][]byte{
 		/* 0x01 */ {REG_OPMODE, RF_OPMODE_SEQUENCER_ON | RF_OPMODE_LISTEN_OFF | RF_OPMODE_STANDBY},
 		/* 0x02 */ {REG_DATAMODUL, RF_DATAMODUL_DATAMODE_PACKET | RF_DATAMODUL_MODULATIONTYPE_FSK | RF_DATAMODUL_MODULATIONSHAPING_00}, // no shaping
@@ -148,20 +322,18 @@ func (r *Device) setup() error {
 		/* 0x2F */ {REG_SYNCVALUE1, 0x2D}, // attempt to make this compatible with sync1 byte of RFM12B lib
 		/* 0x30 */ {REG_SYNCVALUE2, r.Config.NetworkID}, // NETWORK ID
 		/* 0x37 */ {REG_PACKETCONFIG1, RF_PACKET1_FORMAT_VARIABLE | RF_PACKET1_DCFREE_OFF | RF_PACKET1_CRC_ON | RF_PACKET1_CRCAUTOCLEAR_ON | RF_PACKET1_ADRSFILTERING_OFF},
-		/* 0x38 */ {REG_PAYLOADLENGTH, 66}, // in variable length mode: the max frame size, not used in TX
+		/* 0x38 */ {REG_PAYLOADLENGTH, 255}, // in variable length mode: the max frame size, not used in TX. Lowered by Encrypt
 		///* 0x39 */ { REG_NODEADRS, nodeID }, // turned off because we're not using address filtering
 		/* 0x3C */ {REG_FIFOTHRESH, RF_FIFOTHRESH_TXSTART_FIFONOTEMPTY | RF_FIFOTHRESH_VALUE}, // TX on FIFO not empty
 		/* 0x3D */ {REG_PACKETCONFIG2, RF_PACKET2_RXRESTARTDELAY_NONE | RF_PACKET2_AUTORXRESTART_ON | RF_PACKET2_AES_OFF}, // RXRESTARTDELAY must match transmitter PA ramp-down time (bitrate dependent)
 		/* 0x6F */ {REG_TESTDAGC, RF_DAGC_IMPROVED_LOWBETA0}, // run DAGC continuously in RX mode for Fading Margin Improvement, recommended default for AfcLowBetaOn=0
 	}
-    fmt.Println("writing first sync value")
 	for data, err := r.readReg(REG_SYNCVALUE1); err == nil && data != 0xAA; data, err = r.readReg(REG_SYNCVALUE1) {
 		err := r.writeReg(REG_SYNCVALUE1, 0xAA)
 		if err != nil {
 			return err
 		}
 	}
-    fmt.Println("writing second sync value")
 	for data, err := r.readReg(REG_SYNCVALUE1); err == nil && data != 0x55; data, err = r.readReg(REG_SYNCVALUE1) {
 		r.writeReg(REG_SYNCVALUE1, 0x55)
 		if err != nil {
@@ -191,30 +363,33 @@ func (r *Device) setup() error {
 }
 
 func (r *Device) waitForMode() error {
-	errChan := make(chan error)
-	go func() {
-		for {
-			reg, err := r.readReg(REG_IRQFLAGS1)
-			if err != nil {
-				errChan <- err
-				break
-			}
-			if reg&RF_IRQFLAGS1_MODEREADY != 0 {
-				errChan <- nil
-				break
-			}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		reg, err := r.readReg(REG_IRQFLAGS1)
+		if err != nil {
+			return err
 		}
-	}()
-	time.AfterFunc(5*time.Second, func() {
-		errChan <- errors.New("timeout")
-	})
-	return <-errChan
+		if reg&RF_IRQFLAGS1_MODEREADY != 0 {
+			return nil
+		}
+	}
+	return errors.New("timeout")
 }
 
-// Encrypt sets the encryption key and enables AES encryption
+// Encrypt sets the encryption key and enables AES encryption, an empty key
+// disables it
 func (r *Device) Encrypt(key []byte) error {
+	r.aesMu.Lock()
+	defer r.aesMu.Unlock()
+	return r.encrypt(key)
+}
+
+// encrypt is Encrypt, the caller holds aesMu
+func (r *Device) encrypt(key []byte) error {
 	var turnOn byte
-	if len(key) == 16 {
+	switch len(key) {
+	case 0:
+	case KeyLen:
 		turnOn = 1
 		tx := make([]byte, 17)
 		tx[0] = REG_AESKEY1 | 0x80
@@ -224,16 +399,46 @@ func (r *Device) Encrypt(key []byte) error {
 		if err != nil {
 			return err
 		}
+	default:
+		return ErrKeyLength
+	}
+	err := r.readWriteReg(REG_PACKETCONFIG2, 0xFE, turnOn)
+	if err != nil {
+		return err
 	}
-	return r.readWriteReg(REG_PACKETCONFIG2, 0xFE, turnOn)
+	// hardware AES can't handle frames longer than the FIFO
+	maxLen := byte(255)
+	if turnOn == 1 {
+		maxLen = fifoSize
+	}
+	err = r.writeReg(REG_PAYLOADLENGTH, maxLen)
+	if err != nil {
+		return err
+	}
+	copy(r.aesKey[:], key)
+	r.aesOn = turnOn == 1
+	return nil
+}
+
+// loadedKey returns the key in the AES registers and whether AES is on
+func (r *Device) loadedKey() (Key, bool) {
+	r.aesMu.Lock()
+	defer r.aesMu.Unlock()
+	return r.aesKey, r.aesOn
 }
 
 // SetMode sets operation mode
 func (r *Device) SetMode(newMode byte) error {
+	r.modeMu.Lock()
+	defer r.modeMu.Unlock()
 	if newMode == r.mode {
 		return nil
 	}
-	err := r.readWriteReg(REG_OPMODE, 0xE3, newMode)
+	err := r.applyDIOMapping(newMode)
+	if err != nil {
+		return err
+	}
+	err = r.readWriteReg(REG_OPMODE, 0xE3, newMode)
 	if err != nil {
 		return err
 	}
@@ -253,9 +458,17 @@ func (r *Device) SetMode(newMode byte) error {
 	return nil
 }
 
+// currentMode returns the mode last set
+func (r *Device) currentMode() byte {
+	r.modeMu.Lock()
+	defer r.modeMu.Unlock()
+	return r.mode
+}
+
 // SetModeAndWait sets the mode and waits for it
 func (r *Device) SetModeAndWait(newMode byte) error {
-	err := r.SetMode(RF_OPMODE_STANDBY)
+	start := time.Now()
+	err := r.SetMode(newMode)
 	if err != nil {
 		return err
 	}
@@ -263,6 +476,7 @@ func (r *Device) SetModeAndWait(newMode byte) error {
 	if err != nil {
 		return err
 	}
+	r.metrics.get().ModeSwitch(newMode, time.Since(start))
 	return nil
 }
 
@@ -323,9 +537,217 @@ func (r *Device) SetPowerLevel(powerLevel byte) error {
 	return r.readWriteReg(REG_PALEVEL, 0xE0, r.powerLevel)
 }
 
+// SetFrequency sets the carrier frequency in Hz
+func (r *Device) SetFrequency(hz uint32) error {
+	frf := (uint64(hz) << 19) / FXOSC
+	for i, reg := range []byte{REG_FRFMSB, REG_FRFMID, REG_FRFLSB} {
+		err := r.writeReg(reg, byte(frf>>(16-8*i)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetBitrate sets the bit rate in bits/s
+func (r *Device) SetBitrate(bps uint32) error {
+	if bps == 0 {
+		return errors.New("bitrate must not be zero")
+	}
+	br := FXOSC / bps
+	if br > 0xFFFF {
+		return fmt.Errorf("bitrate %d too low", bps)
+	}
+	err := r.writeReg(REG_BITRATEMSB, byte(br>>8))
+	if err != nil {
+		return err
+	}
+	return r.writeReg(REG_BITRATELSB, byte(br))
+}
+
+// Frequency reads back the carrier frequency in Hz
+func (r *Device) Frequency() (uint32, error) {
+	var frf uint64
+	for _, reg := range []byte{REG_FRFMSB, REG_FRFMID, REG_FRFLSB} {
+		v, err := r.readReg(reg)
+		if err != nil {
+			return 0, err
+		}
+		frf = frf<<8 | uint64(v)
+	}
+	return uint32(frf * FXOSC >> 19), nil
+}
+
+// Bitrate reads back the bit rate in bits/s
+func (r *Device) Bitrate() (uint32, error) {
+	br, err := r.bitrateDivider()
+	if err != nil {
+		return 0, err
+	}
+	return FXOSC / br, nil
+}
+
+// bitrateDivider reads the bit rate registers, FXOSC divided by the bit
+// rate
+func (r *Device) bitrateDivider() (uint32, error) {
+	msb, err := r.readReg(REG_BITRATEMSB)
+	if err != nil {
+		return 0, err
+	}
+	lsb, err := r.readReg(REG_BITRATELSB)
+	if err != nil {
+		return 0, err
+	}
+	br := uint32(msb)<<8 | uint32(lsb)
+	if br == 0 {
+		return 0, errors.New("bitrate register is zero")
+	}
+	return br, nil
+}
+
+// PowerLevel returns the TX power set with SetPowerLevel
+func (r *Device) PowerLevel() byte {
+	return r.powerLevel
+}
+
+// Register is the value of a radio register
+type Register struct {
+	Addr  byte
+	Value byte
+}
+
+// lastConfigReg is the last of the contiguous configuration registers, a
+// few test registers follow at scattered addresses
+const lastConfigReg = 0x4F
+
+// testRegs are the test registers that are documented and matter for
+// the sensitivity and output power
+var testRegs = []byte{REG_TESTLNA, REG_TESTPA1, REG_TESTPA2, REG_TESTDAGC, REG_TESTAFC}
+
+// Registers reads every configuration register, skipping the FIFO, and
+// the documented test registers
+func (r *Device) Registers() ([]Register, error) {
+	regs := make([]Register, 0, lastConfigReg+len(testRegs))
+	addrs := make([]byte, 0, cap(regs))
+	for addr := byte(1); addr <= lastConfigReg; addr++ {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range append(addrs, testRegs...) {
+		v, err := r.readReg(addr)
+		if err != nil {
+			return nil, err
+		}
+		regs = append(regs, Register{Addr: addr, Value: v})
+	}
+	return regs, nil
+}
+
+// Version reads the silicon revision, 0x24 for the RFM69
+func (r *Device) Version() (byte, error) {
+	return r.readReg(REG_VERSION)
+}
+
+// temperature sensor offset, the sensor is uncalibrated
+const courseTempCoef = -90
+
+// Temperature measures the die temperature in degrees Celsius, cal
+// corrects the offset of the individual chip. Receiving stops for the
+// duration of the measurement
+func (r *Device) Temperature(cal int) (int, error) {
+	prev := r.currentMode()
+	err := r.SetModeAndWait(RF_OPMODE_STANDBY)
+	if err != nil {
+		return 0, err
+	}
+	err = r.writeReg(REG_TEMP1, RF_TEMP1_MEAS_START)
+	if err != nil {
+		return 0, err
+	}
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for {
+		v, err := r.readReg(REG_TEMP1)
+		if err != nil {
+			return 0, err
+		}
+		if v&RF_TEMP1_MEAS_RUNNING == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return 0, errors.New("timeout measuring temperature")
+		}
+	}
+	v, err := r.readReg(REG_TEMP2)
+	if err != nil {
+		return 0, err
+	}
+	err = r.SetMode(prev)
+	if err != nil {
+		return 0, err
+	}
+	return int(^v) + courseTempCoef + cal, nil
+}
+
+// low battery detector thresholds in volts, see RegLowBat
+var lowBatTrims = []struct {
+	volts float64
+	trim  byte
+}{
+	{1.695, RF_LOWBAT_TRIM_1695},
+	{1.764, RF_LOWBAT_TRIM_1764},
+	{1.835, RF_LOWBAT_TRIM_1835},
+	{1.905, RF_LOWBAT_TRIM_1905},
+	{1.976, RF_LOWBAT_TRIM_1976},
+	{2.045, RF_LOWBAT_TRIM_2045},
+	{2.116, RF_LOWBAT_TRIM_2116},
+	{2.185, RF_LOWBAT_TRIM_2185},
+}
+
+// SetLowBatteryThreshold enables the low battery detector using the trim
+// value closest to volts
+func (r *Device) SetLowBatteryThreshold(volts float64) error {
+	first, last := lowBatTrims[0], lowBatTrims[len(lowBatTrims)-1]
+	if volts < first.volts || volts > last.volts {
+		return fmt.Errorf("low battery threshold %.3fV out of range (%.3fV-%.3fV)", volts, first.volts, last.volts)
+	}
+	trim := first
+	for _, t := range lowBatTrims {
+		if math.Abs(t.volts-volts) < math.Abs(trim.volts-volts) {
+			trim = t
+		}
+	}
+	r.lowBatMu.Lock()
+	defer r.lowBatMu.Unlock()
+	err := r.writeReg(REG_LOWBAT, RF_LOWBAT_ON|trim.trim)
+	if err != nil {
+		return err
+	}
+	r.lowBatOn = true
+	r.lowBat = false
+	return nil
+}
+
+// checkLowBattery fires OnLowBattery when the monitor flag trips
+func (r *Device) checkLowBattery() error {
+	r.lowBatMu.Lock()
+	defer r.lowBatMu.Unlock()
+	if !r.lowBatOn {
+		return nil
+	}
+	reg, err := r.readReg(REG_LOWBAT)
+	if err != nil {
+		return err
+	}
+	low := reg&RF_LOWBAT_MONITOR != 0
+	if low && !r.lowBat && r.OnLowBattery != nil {
+		go r.OnLowBattery()
+	}
+	r.lowBat = low
+	return nil
+}
+
 func (r *Device) canSend() (bool, error) {
 	// if signal stronger than -100dBm is detected assume channel activity
-	if r.mode == RF_OPMODE_RECEIVER {
+	if r.currentMode() == RF_OPMODE_RECEIVER {
 		rssi, err := r.readRSSI(false)
 		if err != nil {
 			return false, err
@@ -338,6 +760,28 @@ func (r *Device) canSend() (bool, error) {
 	return false, nil
 }
 
+// waitForChannel backs off while another node is transmitting, frames
+// arriving meanwhile are received. After csmaTimeout it sends anyway
+func (r *Device) waitForChannel() error {
+	deadline := time.Now().Add(csmaTimeout)
+	for time.Now().Before(deadline) {
+		if r.currentMode() != RF_OPMODE_RECEIVER {
+			return nil
+		}
+		free, err := r.canSend()
+		if err != nil || free {
+			return err
+		}
+		r.metrics.get().CSMABackoff()
+		time.Sleep(time.Duration(1+rand.Intn(10)) * time.Millisecond)
+		err = r.handleIRQ()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Device) readRSSI(forceTrigger bool) (rssi int, err error) {
 	if forceTrigger {
 		// RSSI trigger not needed if DAGC is in continuous mode
@@ -372,44 +816,89 @@ func (r *Device) readWriteReg(reg, andMask, orMask byte) error {
 	return r.writeReg(reg, regValue)
 }
 
-func (r *Device) writeFifo(data *Data) error {
-	buffersize := len(data.Data)
-	if buffersize > MaxDataLen {
-		buffersize = MaxDataLen
-	}
-	tx := make([]byte, buffersize+5)
+// writeFifo writes a frame to the FIFO, it returns what did not fit to be
+// written with refill once the transmission has started
+func (r *Device) writeFifo(data *Data) ([]byte, error) {
+	tx := make([]byte, len(data.Data)+5)
 	// write to FIFO
 	tx[0] = REG_FIFO | 0x80
-	tx[1] = byte(buffersize + 3)
+	tx[1] = byte(len(data.Data) + 3)
 	tx[2] = data.ToAddress
 	tx[3] = r.Config.NodeID
+	if data.Replayed {
+		tx[3] = data.FromAddress
+	}
 	if data.RequestAck {
 		tx[4] = 0x40
 	}
 	if data.SendAck {
 		tx[4] = 0x80
 	}
-	copy(tx[5:], data.Data[:buffersize])
+	tx[4] |= byte(data.Kind) & kindMask
+	copy(tx[5:], data.Data)
+	var rest []byte
+	if len(tx) > fifoSize+1 {
+		tx, rest = tx[:fifoSize+1], tx[fifoSize+1:]
+	}
 	rx := make([]byte, len(tx))
 	err := r.spiDevice.Tx(tx, rx)
-	return err
+	return rest, err
 }
 
-func (r *Device) readFifo() (Data, error) {
+// refill writes the rest of a frame longer than the FIFO while it is sent,
+// whenever the FIFO has drained to the threshold
+func (r *Device) refill(rest []byte) error {
+	deadline := time.Now().Add(time.Second)
+	for len(rest) > 0 {
+		if time.Now().After(deadline) {
+			return errors.New("timeout refilling the FIFO")
+		}
+		flags, err := r.readReg(REG_IRQFLAGS2)
+		if err != nil {
+			return err
+		}
+		if flags&RF_IRQFLAGS2_FIFOLEVEL != 0 {
+			continue
+		}
+		// at most RF_FIFOTHRESH_VALUE bytes are left in the FIFO
+		n := fifoSize - RF_FIFOTHRESH_VALUE - 1
+		if n > len(rest) {
+			n = len(rest)
+		}
+		tx := append([]byte{REG_FIFO | 0x80}, rest[:n]...)
+		err = r.spiDevice.Tx(tx, make([]byte, len(tx)))
+		if err != nil {
+			return err
+		}
+		rest = rest[n:]
+	}
+	return nil
+}
+
+// maxDataLen is the largest payload the radio can send as configured
+func (r *Device) maxDataLen() int {
+	if _, on := r.loadedKey(); on {
+		return maxAESDataLen
+	}
+	return MaxLongDataLen
+}
+
+// readFifo reads a frame, it also returns the raw FIFO contents
+func (r *Device) readFifo() (Data, []byte, error) {
 	var err error
 	data := Data{}
 	data.Rssi, err = r.readRSSI(false)
 	if err != nil {
-		return data, err
+		return data, nil, err
 	}
 	tx := new([70]byte)
 	tx[0] = REG_FIFO & 0x7f
 	rx := make([]byte, len(tx[:3]))
 	err = r.spiDevice.Tx(tx[:3], rx)
 	if err != nil {
-		return data, err
+		return data, nil, err
 	}
-	data.ToAddress = rx[2]
+	head := rx[1:3]
 	length := rx[1] - 3
 	if length > 66 {
 		length = 66
@@ -417,11 +906,93 @@ func (r *Device) readFifo() (Data, error) {
 	rx = make([]byte, len(tx[:length+3]))
 	err = r.spiDevice.Tx(tx[:length+3], rx)
 	if err != nil {
-		return data, err
+		return data, nil, err
 	}
-	data.FromAddress = rx[1]
-	data.SendAck = bool(rx[2]&0x80 > 0)
-	data.RequestAck = bool(rx[2]&0x40 > 0)
-	data.Data = rx[3:]
-	return data, nil
+	raw := append(head, rx[1:]...)
+	parseFrame(raw[1:], &data)
+	return data, raw, nil
+}
+
+// readStream reads a frame while it is being received, frames longer than
+// the FIFO do not fit otherwise. Bytes are read as they arrive except the
+// last, which waits for PayloadReady so the CRC result is known. raw is nil
+// if the frame was dropped or cut short
+func (r *Device) readStream() (data Data, raw []byte, crcOK bool, err error) {
+	data.Rssi, err = r.readRSSI(false)
+	if err != nil {
+		return data, nil, false, err
+	}
+	size := -1 // including the length byte, unknown until it is read
+	last := time.Now()
+	for size < 0 || len(raw) < size {
+		flags, err := r.readReg(REG_IRQFLAGS2)
+		if err != nil {
+			return data, nil, false, err
+		}
+		switch {
+		case flags&RF_IRQFLAGS2_FIFOOVERRUN != 0:
+			return data, nil, false, nil
+		case flags&RF_IRQFLAGS2_PAYLOADREADY != 0:
+			if size < 0 {
+				raw, err = r.fifoRead(1)
+				if err != nil {
+					return data, nil, false, err
+				}
+				size = int(raw[0]) + 1
+			}
+			rest, err := r.fifoRead(size - len(raw))
+			if err != nil {
+				return data, nil, false, err
+			}
+			raw = append(raw, rest...)
+			crcOK = flags&RF_IRQFLAGS2_CRCOK != 0
+		case flags&RF_IRQFLAGS2_FIFONOTEMPTY != 0 && (size < 0 || len(raw) < size-1):
+			b, err := r.fifoRead(1)
+			if err != nil {
+				return data, nil, false, err
+			}
+			if size < 0 {
+				size = int(b[0]) + 1
+			}
+			raw = append(raw, b...)
+			last = time.Now()
+		case time.Since(last) > streamGap:
+			return data, nil, false, nil
+		}
+	}
+	if size < 4 {
+		// too short for the header
+		return data, nil, false, nil
+	}
+	parseFrame(raw[1:], &data)
+	return data, raw, crcOK, nil
+}
+
+// fifoRead reads n bytes from the FIFO
+func (r *Device) fifoRead(n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	tx := make([]byte, n+1)
+	tx[0] = REG_FIFO & 0x7f
+	rx := make([]byte, len(tx))
+	err := r.spiDevice.Tx(tx, rx)
+	return rx[1:], err
+}
+
+// parseFrame fills the header and payload of d from a frame without its
+// length byte
+func parseFrame(msg []byte, d *Data) {
+	d.ToAddress = msg[0]
+	d.FromAddress = msg[1]
+	d.SendAck = msg[2]&0x80 > 0
+	d.RequestAck = msg[2]&0x40 > 0
+	d.Kind = Kind(msg[2] & kindMask)
+	d.Data = msg[3:]
+}
+
+// readPadding reads what is left of the last AES block of a frame of
+// length bytes (length byte excluded)
+func (r *Device) readPadding(length int) ([]byte, error) {
+	return r.fifoRead((aesBlock - length%aesBlock) % aesBlock)
 }
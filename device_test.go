@@ -1,28 +1,586 @@
 package rfm69
 
 import (
-	"log"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3"
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpiotest"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi"
 )
 
-func main() {
-	log.Print("Start")
-    /*
-	pin, err := gpio.OpenPin(irqPin, gpio.ModeInput)
+// fakeRadio is an spi.Port and spi.Conn emulating the registers and FIFO
+// of the SX1231 closely enough to run a Device against it
+type fakeRadio struct {
+	mu     sync.Mutex
+	regs   [0x80]byte
+	rxFifo []byte   // frame waiting to be read
+	air    []byte   // rest of a long frame still being received
+	crcOK  bool     // CRC result of the frame on air
+	txFifo []byte   // bytes written since the last transmission
+	txLong bool     // txFifo is a long frame being streamed in TX
+	sent   [][]byte // FIFO contents of every transmitted frame
+	writes map[byte][]byte
+
+	// onTransmit, if set, is called with every transmitted frame
+	onTransmit func(fifo []byte)
+	outbox     [][]byte // transmitted, not yet handed to onTransmit
+	inbox      [][]byte // received while the FIFO was busy
+	idle       bool     // the flags were read with the FIFO empty
+	sending    bool     // PacketSent not yet seen clear since the last TX
+	unread     []byte   // frame taken from inbox, nothing read from it yet
+}
+
+func newFakeRadio() *fakeRadio {
+	f := &fakeRadio{writes: make(map[byte][]byte)}
+	f.regs[REG_VERSION] = 0x24
+	f.regs[REG_RSSIVALUE] = 0xFF // -127 dBm, a quiet channel
+	return f
+}
+
+func (f *fakeRadio) String() string                    { return "fake" }
+func (f *fakeRadio) Duplex() conn.Duplex               { return conn.Full }
+func (f *fakeRadio) LimitSpeed(physic.Frequency) error { return nil }
+
+func (f *fakeRadio) Connect(physic.Frequency, spi.Mode, int) (spi.Conn, error) {
+	return f, nil
+}
+
+func (f *fakeRadio) TxPackets(p []spi.Packet) error {
+	for _, pkt := range p {
+		err := f.Tx(pkt.W, pkt.R)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		defer pin.Close()
+	}
+	return nil
+}
 
-		spiBus, err := spidev.NewSPIDevice("/dev/spidev0.0")
-		if err != nil {
-			panic(err)
+// Tx implements a register access, auto incrementing the address except
+// for the FIFO
+func (f *fakeRadio) Tx(w, r []byte) error {
+	f.mu.Lock()
+	addr := w[0] & 0x7F
+	write := w[0]&0x80 != 0
+	for i := 1; i < len(w); i++ {
+		a := addr
+		if addr != REG_FIFO {
+			a += byte(i - 1)
+		}
+		if write {
+			f.write(a, w[i])
+		} else if r != nil {
+			r[i] = f.read(a)
+		}
+	}
+	out, hook := f.outbox, f.onTransmit
+	f.outbox = nil
+	f.mu.Unlock()
+	if hook != nil {
+		for _, fifo := range out {
+			hook(fifo)
 		}
-		defer spiBus.Close()
+	}
+	return nil
+}
+
+func (f *fakeRadio) write(addr, v byte) {
+	f.writes[addr] = append(f.writes[addr], v)
+	switch addr {
+	case REG_FIFO:
+		if !f.txLong && len(f.txFifo) == fifoSize {
+			f.regs[REG_IRQFLAGS2] |= RF_IRQFLAGS2_FIFOOVERRUN
+			return
+		}
+		f.txFifo = append(f.txFifo, v)
+		if f.txLong && len(f.txFifo) == int(f.txFifo[0])+1 {
+			f.transmit()
+		}
+		return
+	case REG_IRQFLAGS2:
+		if v&RF_IRQFLAGS2_FIFOOVERRUN != 0 {
+			f.rxFifo, f.txFifo = nil, nil
+			f.regs[REG_IRQFLAGS2] &^= RF_IRQFLAGS2_FIFOOVERRUN | RF_IRQFLAGS2_PAYLOADREADY | RF_IRQFLAGS2_CRCOK
+		}
+		return
+	case REG_OPMODE:
+		if f.unread != nil && v&0x1C != RF_OPMODE_RECEIVER {
+			// leaving RX before reading the frame, receive it again later
+			f.inbox = append([][]byte{f.unread}, f.inbox...)
+			f.unread, f.rxFifo, f.air = nil, nil, nil
+			f.regs[REG_IRQFLAGS1] &^= RF_IRQFLAGS1_SYNCADDRESSMATCH
+			f.regs[REG_IRQFLAGS2] &^= RF_IRQFLAGS2_PAYLOADREADY | RF_IRQFLAGS2_CRCOK
+		}
+		f.regs[REG_IRQFLAGS1] |= RF_IRQFLAGS1_MODEREADY
+		f.regs[REG_IRQFLAGS2] &^= RF_IRQFLAGS2_PACKETSENT
+		f.sending = false
+		f.txLong = false
+		if v&0x1C == RF_OPMODE_TRANSMITTER && len(f.txFifo) > 0 {
+			if len(f.txFifo) < int(f.txFifo[0])+1 {
+				// the FIFO drains as fast as the rest is written
+				f.txLong = true
+			} else {
+				f.transmit()
+			}
+		}
+	case REG_PACKETCONFIG2:
+		if v&RF_PACKET2_RXRESTART != 0 {
+			v &^= RF_PACKET2_RXRESTART
+			f.regs[REG_IRQFLAGS1] &^= RF_IRQFLAGS1_TIMEOUT | RF_IRQFLAGS1_RSSI | RF_IRQFLAGS1_SYNCADDRESSMATCH
+		}
+	case REG_RSSICONFIG:
+		v |= RF_RSSI_DONE
+	case REG_AFCFEI:
+		if v&RF_AFCFEI_FEI_START != 0 {
+			v = v&^RF_AFCFEI_FEI_START | RF_AFCFEI_FEI_DONE
+		}
+	}
+	f.regs[addr] = v
+}
+
+// transmit sends the frame in txFifo
+func (f *fakeRadio) transmit() {
+	f.sent = append(f.sent, f.txFifo)
+	f.outbox = append(f.outbox, f.txFifo)
+	f.txFifo = nil
+	f.txLong = false
+	f.sending = true
+}
 
-		rfm, err := NewDevice(spiBus, pin, 1, 10, false)
+func (f *fakeRadio) read(addr byte) byte {
+	switch addr {
+	case REG_IRQFLAGS1:
+		f.next()
+	case REG_IRQFLAGS2:
+		// more of a long frame arrives whenever the flags are checked
+		if len(f.air) > 0 {
+			n := fifoSize - len(f.rxFifo)
+			if n > len(f.air) {
+				n = len(f.air)
+			}
+			f.rxFifo = append(f.rxFifo, f.air[:n]...)
+			f.air = f.air[n:]
+			if len(f.air) == 0 {
+				f.payloadReady()
+			}
+		}
+		v := f.regs[addr]
+		if f.sending {
+			// the frame takes a while on air, so a poller sees PacketSent
+			// rise even after back to back transmissions
+			f.sending = false
+			f.regs[addr] |= RF_IRQFLAGS2_PACKETSENT
+		}
+		if len(f.rxFifo) > 0 {
+			v |= RF_IRQFLAGS2_FIFONOTEMPTY
+		}
+		return v
+	case REG_FIFO:
+		if len(f.rxFifo) == 0 {
+			return 0
+		}
+		v := f.rxFifo[0]
+		f.rxFifo = f.rxFifo[1:]
+		f.unread = nil
+		if len(f.rxFifo) == 0 && len(f.air) == 0 {
+			f.regs[REG_IRQFLAGS1] &^= RF_IRQFLAGS1_SYNCADDRESSMATCH
+			f.regs[REG_IRQFLAGS2] &^= RF_IRQFLAGS2_PAYLOADREADY | RF_IRQFLAGS2_CRCOK
+		}
+		return v
+	case REG_TEMP1:
+		return f.regs[addr] &^ RF_TEMP1_MEAS_RUNNING
+	}
+	return f.regs[addr]
+}
+
+// set changes a register without logging it as a write
+func (f *fakeRadio) set(addr, v byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.regs[addr] = v
+}
+
+// raise sets bits of a register without logging it as a write
+func (f *fakeRadio) raise(addr, bits byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.regs[addr] |= bits
+}
+
+// sync sets SyncAddressMatch as if a packet had started
+func (f *fakeRadio) sync() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.regs[REG_IRQFLAGS1] |= RF_IRQFLAGS1_SYNCADDRESSMATCH
+}
+
+// receive puts a frame (to, from, control, payload) into the FIFO as if it
+// had just been received
+func (f *fakeRadio) receive(frame []byte, crcOK bool) {
+	f.receiveFifo(append([]byte{byte(len(frame))}, frame...), crcOK)
+}
+
+// receiveFifo puts fifo into the FIFO as if a frame had just been
+// received. What does not fit arrives while the FIFO is read
+func (f *fakeRadio) receiveFifo(fifo []byte, crcOK bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.load(fifo, crcOK)
+}
+
+// deliver queues fifo to be received once the FIFO is free
+func (f *fakeRadio) deliver(fifo []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inbox = append(f.inbox, fifo)
+}
+
+// next receives the first queued frame if the FIFO is empty and the radio
+// listens. The flags have to be seen clear once in between, like a poller
+// would on air
+func (f *fakeRadio) next() {
+	busy := len(f.rxFifo) > 0 || len(f.air) > 0 || f.regs[REG_IRQFLAGS2]&RF_IRQFLAGS2_PAYLOADREADY != 0
+	if busy || len(f.inbox) == 0 || f.regs[REG_OPMODE]&0x1C != RF_OPMODE_RECEIVER {
+		f.idle = false
+		return
+	}
+	if !f.idle {
+		f.idle = true
+		return
+	}
+	f.idle = false
+	f.unread = f.inbox[0]
+	f.inbox = f.inbox[1:]
+	f.load(f.unread, true)
+}
+
+func (f *fakeRadio) load(fifo []byte, crcOK bool) {
+	f.rxFifo, f.air, f.crcOK = fifo, nil, crcOK
+	if len(fifo) > fifoSize {
+		f.rxFifo, f.air = fifo[:fifoSize], fifo[fifoSize:]
+	}
+	f.regs[REG_IRQFLAGS1] |= RF_IRQFLAGS1_SYNCADDRESSMATCH
+	if len(f.air) == 0 {
+		f.payloadReady()
+	}
+}
+
+// payloadReady flags the frame in the FIFO as complete
+func (f *fakeRadio) payloadReady() {
+	f.regs[REG_IRQFLAGS2] |= RF_IRQFLAGS2_PAYLOADREADY
+	if f.crcOK {
+		f.regs[REG_IRQFLAGS2] |= RF_IRQFLAGS2_CRCOK
+	}
+}
+
+// transmitted waits for the n-th frame sent, counting from 1
+func (f *fakeRadio) transmitted(t *testing.T, n int) []byte {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		if len(f.sent) >= n {
+			sent := f.sent[n-1]
+			f.mu.Unlock()
+			return sent
+		}
+		f.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("frame %d never transmitted", n)
+	return nil
+}
+
+// written returns the values written to a register
+func (f *fakeRadio) written(addr byte) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]byte(nil), f.writes[addr]...)
+}
+
+// newTestDevice runs a Device on a fake radio, polling its interrupt
+// flags every millisecond
+func newTestDevice(t *testing.T, options *RFMOptions) (*Device, *fakeRadio) {
+	t.Helper()
+	if options == nil {
+		options = &RFMOptions{NodeID: 1, NetworkID: 100}
+	}
+	if options.PollInterval == 0 {
+		options.PollInterval = time.Millisecond
+	}
+	f := newFakeRadio()
+	d, err := NewDevice(f, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d, f
+}
+
+// receive waits for a frame on c
+func receive(t *testing.T, c <-chan Data) Data {
+	t.Helper()
+	select {
+	case d := <-c:
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("no frame received")
+		return Data{}
+	}
+}
+
+func TestDeviceSendAndReceive(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+
+	d.Send(&Data{ToAddress: 2, RequestAck: true, Data: []byte("hi")})
+	sent := f.transmitted(t, 1)
+	want := []byte{5, 2, 1, 0x40, 'h', 'i'}
+	if string(sent) != string(want) {
+		t.Fatalf("sent % x, want % x", sent, want)
+	}
+
+	f.receive([]byte{1, 2, 0x80, 'o', 'k'}, true)
+	select {
+	case got := <-d.Receive():
+		if got.FromAddress != 2 || got.ToAddress != 1 || !got.SendAck || string(got.Data) != "ok" {
+			t.Fatalf("received %+v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing received")
+	}
+}
+
+func TestDeviceLongFrames(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+
+	payload := make([]byte, MaxLongDataLen)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	d.Send(&Data{ToAddress: 2, Data: payload})
+	sent := f.transmitted(t, 1)
+	want := append([]byte{255, 2, 1, 0}, payload...)
+	if string(sent) != string(want) {
+		t.Fatalf("sent % x, want % x", sent, want)
+	}
+
+	f.receive(append([]byte{1, 2, 0}, payload[:200]...), true)
+	got := receive(t, d.Receive())
+	if got.FromAddress != 2 || string(got.Data) != string(payload[:200]) {
+		t.Fatalf("received %+v", got)
+	}
+
+	if err := d.Transmit(&Data{ToAddress: 2, Data: append(payload, 0)}); err != ErrPayloadSize {
+		t.Fatalf("oversized payload: %v", err)
+	}
+}
+
+func TestDeviceAESFrameLimit(t *testing.T) {
+	ks := NewKeyStore(time.Hour)
+	ks.Add(100, testKey, time.Now().Add(-time.Minute))
+	d, f := newTestDevice(t, &RFMOptions{NodeID: 1, NetworkID: 100, Keys: ks})
+	m := new(countMetrics)
+	d.SetMetrics(m)
+
+	// the key is loaded on the first idle check, limiting received frames
+	// to the FIFO as well
+	deadline := time.Now().Add(time.Second)
+	for w := f.written(REG_PAYLOADLENGTH); len(w) == 0 || w[len(w)-1] != fifoSize; w = f.written(REG_PAYLOADLENGTH) {
+		if time.Now().After(deadline) {
+			t.Fatalf("payload length writes %v, want the last to be %d", w, fifoSize)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// hardware AES only encrypts what fits the FIFO
+	d.Send(&Data{ToAddress: 2, Data: make([]byte, maxAESDataLen+1)})
+	d.Send(&Data{ToAddress: 2, Data: make([]byte, maxAESDataLen)})
+	if sent := f.transmitted(t, 1); len(sent) != maxAESDataLen+4 {
+		t.Fatalf("sent a %d byte frame, want %d", len(sent), maxAESDataLen+4)
+	}
+	if m.count("loop_error") != 1 {
+		t.Fatalf("%d loop errors, want 1 for the oversized payload", m.count("loop_error"))
+	}
+}
+
+func TestDeviceModeSwitchMetrics(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	m := new(countMetrics)
+	d.SetMetrics(m)
+	// a busy channel is ignored unless CSMA is on
+	f.set(REG_RSSIVALUE, 40)
+
+	d.Send(&Data{ToAddress: 2, Data: []byte{1}})
+	f.transmitted(t, 1)
+	deadline := time.Now().Add(time.Second)
+	for m.mode(RF_OPMODE_RECEIVER) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if m.mode(RF_OPMODE_TRANSMITTER) != 1 || m.mode(RF_OPMODE_RECEIVER) != 1 {
+		t.Fatalf("mode switches %v, want one to TX and one to RX", m.modes)
+	}
+	if m.count("backoff") != 0 {
+		t.Fatalf("%d CSMA backoffs with CSMA off", m.count("backoff"))
+	}
+}
+
+func TestDeviceCSMA(t *testing.T) {
+	d, f := newTestDevice(t, &RFMOptions{NodeID: 1, CSMA: true})
+	m := new(countMetrics)
+	d.SetMetrics(m)
+	f.set(REG_RSSIVALUE, 40)
+
+	start := time.Now()
+	d.Send(&Data{ToAddress: 2, Data: []byte{1}})
+	f.transmitted(t, 1)
+	if m.count("backoff") == 0 {
+		t.Fatal("sent on a busy channel without backing off")
+	}
+	if time.Since(start) < csmaTimeout {
+		t.Fatalf("sent after %v, before the CSMA timeout", time.Since(start))
+	}
+}
+
+func TestDeviceCloseStopsWatchers(t *testing.T) {
+	before := runtime.NumGoroutine()
+	irq := &gpiotest.Pin{N: "irq", EdgesChan: make(chan gpio.Level, 1)}
+	dio3 := &gpiotest.Pin{N: "dio3", EdgesChan: make(chan gpio.Level, 1)}
+	f := newFakeRadio()
+	d, err := NewDevice(f, &RFMOptions{NodeID: 1, IrqPin: irq, Dio3Pin: dio3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dio3.EdgesChan <- gpio.High
+	d.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("%d goroutines left running after Close", n-before)
+	}
+}
+
+func TestPollIRQEdges(t *testing.T) {
+	f := newFakeRadio()
+	r := &Device{spiDevice: f, Config: &RFMOptions{PollInterval: time.Millisecond}, stop: make(chan struct{})}
+	irq := make(chan bool)
+	r.pollIRQ(irq)
+	defer func() {
+		close(r.stop)
+		r.watchers.Wait()
+	}()
+
+	// SyncAddressMatch stays set while a long packet is received
+	f.set(REG_IRQFLAGS1, RF_IRQFLAGS1_SYNCADDRESSMATCH)
+	edges, idle := 0, 0
+	timeout := time.After(350 * time.Millisecond)
+	for done := false; !done; {
+		select {
+		case v := <-irq:
+			if v {
+				edges++
+			} else {
+				idle++
+			}
+		case <-timeout:
+			done = true
+		}
+	}
+	if edges != 1 || idle < 2 {
+		t.Fatalf("got %d edges and %d idle checks, want 1 and at least 2", edges, idle)
+	}
+}
+
+func TestDeviceRegistersIncludeTestRegisters(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	f.set(REG_TESTLNA, 0x2D)
+	f.set(REG_TESTAFC, 0x12)
+
+	regs, err := d.Registers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[byte]byte)
+	for _, r := range regs {
+		got[r.Addr] = r.Value
+	}
+	if _, ok := got[REG_FIFO]; ok {
+		t.Fatal("dump read the FIFO")
+	}
+	for _, addr := range []byte{REG_OPMODE, lastConfigReg, REG_TESTPA1, REG_TESTPA2, REG_TESTDAGC} {
+		if _, ok := got[addr]; !ok {
+			t.Errorf("register %#02x missing", addr)
+		}
+	}
+	if got[REG_TESTLNA] != 0x2D || got[REG_TESTAFC] != 0x12 {
+		t.Fatalf("TestLna %#02x, TestAfc %#02x", got[REG_TESTLNA], got[REG_TESTAFC])
+	}
+}
+
+func TestDeviceSenderAddress(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+
+	// a frame passed on as received is still sent from this node
+	d.Send(&Data{ToAddress: 2, FromAddress: 9, Data: []byte("a")})
+	if sent := f.transmitted(t, 1); sent[2] != 1 {
+		t.Fatalf("sent from %d, want 1", sent[2])
+	}
+	// replays keep the captured sender, node 0 included
+	for i, from := range []byte{9, 0} {
+		d.Send(&Data{ToAddress: 2, FromAddress: from, Replayed: true, Data: []byte("a")})
+		if sent := f.transmitted(t, i+2); sent[2] != from {
+			t.Fatalf("replay sent from %d, want %d", sent[2], from)
+		}
+	}
+}
+
+func TestLowBatteryFiresOnce(t *testing.T) {
+	f := newFakeRadio()
+	fired := make(chan struct{}, 4)
+	r := &Device{spiDevice: f, OnLowBattery: func() { fired <- struct{}{} }}
+	if err := r.SetLowBatteryThreshold(1.5); err == nil {
+		t.Fatal("threshold below the trim range accepted")
+	}
+	err := r.SetLowBatteryThreshold(1.84)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := byte(RF_LOWBAT_ON | RF_LOWBAT_TRIM_1835)
+	if v, _ := r.readReg(REG_LOWBAT); v != on {
+		t.Fatalf("RegLowBat %#02x, want %#02x", v, on)
+	}
+
+	check := func() {
+		t.Helper()
+		err := r.checkLowBattery()
 		if err != nil {
-			log.Fatal(err)
+			t.Fatal(err)
+		}
+	}
+	check()
+	f.set(REG_LOWBAT, on|RF_LOWBAT_MONITOR)
+	check()
+	check()
+	f.set(REG_LOWBAT, on)
+	check()
+	f.set(REG_LOWBAT, on|RF_LOWBAT_MONITOR)
+	check()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-fired:
+		case <-time.After(time.Second):
+			t.Fatalf("fired %d times, want once per drop", i)
 		}
-		log.Print(rfm)
-	*/
+	}
+	select {
+	case <-fired:
+		t.Fatal("fired again while the battery stayed low")
+	case <-time.After(50 * time.Millisecond):
+	}
 }
@@ -0,0 +1,223 @@
+package rfm69
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// DIO identifies one of the DIO0-DIO5 pins
+type DIO int
+
+// DIO pins
+const (
+	DIO0 DIO = iota
+	DIO1
+	DIO2
+	DIO3
+	DIO4
+	DIO5
+)
+
+// DIOSignal is an internal signal that can be routed to a DIO pin
+type DIOSignal int
+
+// DIO signals in packet mode
+const (
+	SignalNone DIOSignal = iota
+	SignalFifoLevel
+	SignalFifoFull
+	SignalFifoNotEmpty
+	SignalSyncAddress
+	SignalRssi
+	SignalTimeout
+	SignalModeReady
+	SignalPayloadReady
+	SignalCrcOk
+	SignalPacketSent
+	SignalTxReady
+	SignalRxReady
+	SignalPllLock
+	SignalAutoMode
+	SignalData
+	SignalClkOut
+)
+
+var signalNames = []string{
+	"None", "FifoLevel", "FifoFull", "FifoNotEmpty", "SyncAddress", "Rssi",
+	"Timeout", "ModeReady", "PayloadReady", "CrcOk", "PacketSent", "TxReady",
+	"RxReady", "PllLock", "AutoMode", "Data", "ClkOut",
+}
+
+func (s DIOSignal) String() string {
+	if s < 0 || int(s) >= len(signalNames) {
+		return fmt.Sprintf("DIOSignal(%d)", int(s))
+	}
+	return signalNames[s]
+}
+
+// dioTable is the packet mode DIO mapping from the SX1231 datasheet,
+// indexed by pin and the 2 bit mapping value
+var dioTable = map[byte][6][4]DIOSignal{
+	RF_OPMODE_SLEEP: {
+		{SignalNone, SignalNone, SignalNone, SignalNone},
+		{SignalFifoLevel, SignalFifoFull, SignalFifoNotEmpty, SignalNone},
+		{SignalFifoNotEmpty, SignalNone, SignalNone, SignalNone},
+		{SignalFifoFull, SignalNone, SignalNone, SignalNone},
+		{SignalNone, SignalNone, SignalNone, SignalNone},
+		{SignalNone, SignalNone, SignalNone, SignalModeReady},
+	},
+	RF_OPMODE_STANDBY: {
+		{SignalNone, SignalNone, SignalNone, SignalNone},
+		{SignalFifoLevel, SignalFifoFull, SignalFifoNotEmpty, SignalNone},
+		{SignalFifoNotEmpty, SignalNone, SignalNone, SignalNone},
+		{SignalFifoFull, SignalNone, SignalNone, SignalNone},
+		{SignalNone, SignalNone, SignalNone, SignalNone},
+		{SignalClkOut, SignalNone, SignalNone, SignalModeReady},
+	},
+	RF_OPMODE_SYNTHESIZER: {
+		{SignalNone, SignalNone, SignalNone, SignalPllLock},
+		{SignalFifoLevel, SignalFifoFull, SignalFifoNotEmpty, SignalPllLock},
+		{SignalFifoNotEmpty, SignalNone, SignalNone, SignalNone},
+		{SignalFifoFull, SignalNone, SignalNone, SignalNone},
+		{SignalNone, SignalNone, SignalNone, SignalPllLock},
+		{SignalClkOut, SignalNone, SignalNone, SignalModeReady},
+	},
+	RF_OPMODE_RECEIVER: {
+		{SignalCrcOk, SignalPayloadReady, SignalSyncAddress, SignalRssi},
+		{SignalFifoLevel, SignalFifoFull, SignalFifoNotEmpty, SignalTimeout},
+		{SignalFifoNotEmpty, SignalData, SignalAutoMode, SignalNone},
+		{SignalFifoFull, SignalRssi, SignalSyncAddress, SignalPllLock},
+		{SignalTimeout, SignalRssi, SignalRxReady, SignalPllLock},
+		{SignalClkOut, SignalData, SignalNone, SignalModeReady},
+	},
+	RF_OPMODE_TRANSMITTER: {
+		{SignalPacketSent, SignalTxReady, SignalNone, SignalPllLock},
+		{SignalFifoLevel, SignalFifoFull, SignalFifoNotEmpty, SignalPllLock},
+		{SignalFifoNotEmpty, SignalData, SignalAutoMode, SignalNone},
+		{SignalFifoFull, SignalTxReady, SignalNone, SignalPllLock},
+		{SignalModeReady, SignalTxReady, SignalNone, SignalPllLock},
+		{SignalClkOut, SignalData, SignalNone, SignalModeReady},
+	},
+}
+
+// defaultDIOMapping routes PayloadReady and PacketSent to DIO0 and, if
+// DIO3 is wired, SyncAddress to DIO3 so the FEI is measured while a
+// packet is on air
+func defaultDIOMapping(dio3 bool) map[byte]*[6]byte {
+	m := make(map[byte]*[6]byte)
+	for mode := range dioTable {
+		m[mode] = new([6]byte)
+	}
+	m[RF_OPMODE_RECEIVER][DIO0] = 1    // PayloadReady
+	m[RF_OPMODE_TRANSMITTER][DIO0] = 0 // PacketSent
+	if dio3 {
+		m[RF_OPMODE_RECEIVER][DIO3] = 2 // SyncAddress
+	}
+	return m
+}
+
+// SetDIOMapping routes signal to dio while the device is in mode, the
+// change is written to the radio on the next mode switch
+func (r *Device) SetDIOMapping(mode byte, dio DIO, signal DIOSignal) error {
+	pins, ok := dioTable[mode]
+	if !ok {
+		return fmt.Errorf("unknown mode %#x", mode)
+	}
+	if dio < DIO0 || dio > DIO5 {
+		return fmt.Errorf("unknown DIO%d", dio)
+	}
+	for value, s := range pins[dio] {
+		if s == signal && s != SignalNone {
+			// SetMode applies the mapping under modeMu as well
+			r.modeMu.Lock()
+			defer r.modeMu.Unlock()
+			r.dioMapping[mode][dio] = byte(value)
+			if mode == r.mode {
+				return r.applyDIOMapping(mode)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%v can not be mapped to DIO%d in mode %#x", signal, dio, mode)
+}
+
+// DIOMapping returns the signal routed to dio in mode
+func (r *Device) DIOMapping(mode byte, dio DIO) DIOSignal {
+	pins, ok := dioTable[mode]
+	if !ok || dio < DIO0 || dio > DIO5 {
+		return SignalNone
+	}
+	r.modeMu.Lock()
+	defer r.modeMu.Unlock()
+	return pins[dio][r.dioMapping[mode][dio]]
+}
+
+// applyDIOMapping writes the mapping of mode to RegDioMapping1/2, the
+// caller holds modeMu
+func (r *Device) applyDIOMapping(mode byte) error {
+	m, ok := r.dioMapping[mode]
+	if !ok || r.inContinuous() {
+		return nil
+	}
+	err := r.writeReg(REG_DIOMAPPING1, m[DIO0]<<6|m[DIO1]<<4|m[DIO2]<<2|m[DIO3])
+	if err != nil {
+		return err
+	}
+	return r.writeReg(REG_DIOMAPPING2, m[DIO4]<<6|m[DIO5]<<4|RF_DIOMAPPING2_CLKOUT_OFF)
+}
+
+// dioPins returns the wired DIO1-DIO5 pins
+func (r *Device) dioPins() []gpio.PinIn {
+	var pins []gpio.PinIn
+	for _, p := range []gpio.PinIn{r.Config.Dio1Pin, r.Config.Dio2Pin, r.Config.Dio3Pin, r.Config.Dio4Pin, r.Config.Dio5Pin} {
+		if p != nil {
+			pins = append(pins, p)
+		}
+	}
+	return pins
+}
+
+// watchDIO feeds rising edges of pin into irq until Close. Continuous mode
+// owns DIO1 and DIO2, their watchers lend the pin over lend and leave it
+// alone until it is handed back
+func (r *Device) watchDIO(pin gpio.PinIn, irq chan<- bool, lend <-chan chan struct{}) {
+	r.watchers.Add(1)
+	go func() {
+		defer r.watchers.Done()
+		configured := false
+		for {
+			select {
+			case <-r.stop:
+				return
+			case back := <-lend:
+				select {
+				case <-back:
+				case <-r.stop:
+					return
+				}
+				configured = false
+				continue
+			default:
+			}
+			if !configured {
+				if err := pin.In(gpio.PullDown, gpio.RisingEdge); err != nil {
+					log.Println(err)
+					// retry later, still answering lend requests
+					select {
+					case <-r.stop:
+						return
+					case <-time.After(time.Second):
+					}
+					continue
+				}
+				configured = true
+			}
+			if pin.WaitForEdge(100*time.Millisecond) && !r.signal(irq, true) {
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,84 @@
+package rfm69
+
+import "testing"
+
+func TestDIOMapping(t *testing.T) {
+	f := newFakeRadio()
+	r := &Device{spiDevice: f, Config: &RFMOptions{}, dioMapping: defaultDIOMapping(false)}
+
+	if s := r.DIOMapping(RF_OPMODE_RECEIVER, DIO0); s != SignalPayloadReady {
+		t.Fatalf("DIO0 in RX maps %v", s)
+	}
+	if s := r.DIOMapping(RF_OPMODE_TRANSMITTER, DIO0); s != SignalPacketSent {
+		t.Fatalf("DIO0 in TX maps %v", s)
+	}
+
+	err := r.SetDIOMapping(RF_OPMODE_RECEIVER, DIO3, SignalSyncAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := r.DIOMapping(RF_OPMODE_RECEIVER, DIO3); s != SignalSyncAddress {
+		t.Fatalf("DIO3 in RX maps %v", s)
+	}
+	if w := f.written(REG_DIOMAPPING1); len(w) != 0 {
+		t.Fatalf("mapping of another mode written: % x", w)
+	}
+
+	// the device sleeps, its mapping is written right away
+	err = r.SetDIOMapping(RF_OPMODE_SLEEP, DIO5, SignalModeReady)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := f.written(REG_DIOMAPPING2); len(w) != 1 || w[0] != 0x30|RF_DIOMAPPING2_CLKOUT_OFF {
+		t.Fatalf("RegDioMapping2 written % x", w)
+	}
+
+	for _, tc := range []struct {
+		mode   byte
+		dio    DIO
+		signal DIOSignal
+	}{
+		{RF_OPMODE_RECEIVER, DIO0, SignalPacketSent},
+		{RF_OPMODE_RECEIVER, DIO2, SignalNone},
+		{RF_OPMODE_RECEIVER, DIO5 + 1, SignalClkOut},
+		{0xFF, DIO0, SignalCrcOk},
+	} {
+		if err := r.SetDIOMapping(tc.mode, tc.dio, tc.signal); err == nil {
+			t.Errorf("%v on DIO%d in mode %#x accepted", tc.signal, tc.dio, tc.mode)
+		}
+	}
+	if s := DIOSignal(99).String(); s != "DIOSignal(99)" {
+		t.Fatalf("unknown signal named %q", s)
+	}
+}
+
+func TestDIOMappingWhileRunning(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	stop, mapped := make(chan struct{}), make(chan DIOSignal)
+	go func() {
+		signal := SignalFifoLevel
+		for {
+			select {
+			case <-stop:
+				mapped <- signal
+				return
+			default:
+			}
+			signal = SignalFifoLevel + SignalFifoFull - signal
+			err := d.SetDIOMapping(RF_OPMODE_TRANSMITTER, DIO1, signal)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	// every frame switches the device to TX and back
+	for i := 0; i < 20; i++ {
+		d.Send(&Data{ToAddress: 2, Data: []byte{byte(i)}})
+	}
+	f.transmitted(t, 20)
+	close(stop)
+	signal := <-mapped
+	if s := d.DIOMapping(RF_OPMODE_TRANSMITTER, DIO1); s != signal {
+		t.Fatalf("DIO1 in TX maps %v, want %v", s, signal)
+	}
+}
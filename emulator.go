@@ -0,0 +1,118 @@
+package rfm69
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned when using a closed radio
+var ErrClosed = errors.New("radio closed")
+
+// Emulator is an in memory Radio for tests. Frames are received with
+// Inject, transmitted frames are handed to OnTransmit, which may inject
+// them into another emulator to connect two routers
+type Emulator struct {
+	address byte
+	rx      chan Data
+	done    chan struct{}
+	once    sync.Once
+
+	mu     sync.RWMutex
+	mode   byte
+	closed bool
+
+	// Rssi is reported by RSSI and set on injected frames without one
+	Rssi int
+	// OnTransmit is called with every frame sent, FromAddress filled in
+	OnTransmit func(Data)
+}
+
+var _ Radio = (*Emulator)(nil)
+
+// NewEmulator creates an emulated radio with the given node ID
+func NewEmulator(address byte) *Emulator {
+	return &Emulator{
+		address: address,
+		rx:      make(chan Data, rxBuffer),
+		done:    make(chan struct{}),
+		mode:    RF_OPMODE_RECEIVER,
+		Rssi:    -50,
+	}
+}
+
+// Inject delivers d as if it had been received, it blocks while the
+// receive channel is full
+func (e *Emulator) Inject(d Data) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return ErrClosed
+	}
+	if d.Rssi == 0 {
+		d.Rssi = e.Rssi
+	}
+	select {
+	case e.rx <- d:
+		return nil
+	case <-e.done:
+		return ErrClosed
+	}
+}
+
+// Transmit implements Radio
+func (e *Emulator) Transmit(d *Data) error {
+	e.mu.RLock()
+	closed := e.closed
+	e.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	if len(d.Data) > MaxLongDataLen {
+		return ErrPayloadSize
+	}
+	sent := *d
+	if !sent.Replayed {
+		sent.FromAddress = e.address
+	}
+	sent.Data = append([]byte(nil), d.Data...)
+	if e.OnTransmit != nil {
+		e.OnTransmit(sent)
+	}
+	return nil
+}
+
+// Receive implements Radio
+func (e *Emulator) Receive() <-chan Data {
+	return e.rx
+}
+
+// SetMode implements Radio
+func (e *Emulator) SetMode(mode byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.mode = mode
+	return nil
+}
+
+// RSSI implements Radio
+func (e *Emulator) RSSI() (int, error) {
+	return e.Rssi, nil
+}
+
+// Address implements Radio
+func (e *Emulator) Address() byte {
+	return e.address
+}
+
+// Close implements Radio
+func (e *Emulator) Close() error {
+	e.once.Do(func() {
+		// unblock pending injects before taking the write lock
+		close(e.done)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.closed = true
+		close(e.rx)
+	})
+	return nil
+}
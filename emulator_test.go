@@ -0,0 +1,43 @@
+package rfm69
+
+import (
+	"testing"
+)
+
+func TestEmulatorTransmitFillsSender(t *testing.T) {
+	e := NewEmulator(7)
+	var sent Data
+	e.OnTransmit = func(d Data) { sent = d }
+	payload := []byte("hi")
+	err := e.Transmit(&Data{ToAddress: 3, Data: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload[0] = 'x'
+	if sent.FromAddress != 7 || sent.ToAddress != 3 || string(sent.Data) != "hi" {
+		t.Fatalf("sent %+v", sent)
+	}
+}
+
+func TestEmulatorClose(t *testing.T) {
+	e := NewEmulator(1)
+	err := e.Inject(Data{FromAddress: 2, Data: []byte{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := <-e.Receive()
+	if d.Rssi != e.Rssi {
+		t.Fatalf("rssi %d, want the emulator default %d", d.Rssi, e.Rssi)
+	}
+	e.Close()
+	e.Close()
+	if _, ok := <-e.Receive(); ok {
+		t.Fatal("receive channel still open")
+	}
+	if err := e.Inject(Data{}); err != ErrClosed {
+		t.Fatalf("inject after close: %v", err)
+	}
+	if err := e.Transmit(&Data{}); err != ErrClosed {
+		t.Fatalf("transmit after close: %v", err)
+	}
+}
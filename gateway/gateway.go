@@ -0,0 +1,68 @@
+// Package gateway holds the JSON representation of frames shared by the
+// gateways exposing a Router to other programs
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+// Frame is the JSON form of a received frame
+type Frame struct {
+	From   byte      `json:"from"`
+	To     byte      `json:"to"`
+	Kind   byte      `json:"kind"`
+	Rssi   int       `json:"rssi"`
+	Ack    bool      `json:"ack"` // the sender requested an ack
+	Hex    string    `json:"hex"`
+	Base64 string    `json:"base64"`
+	Time   time.Time `json:"time"`
+}
+
+// NewFrame converts received data, stamped with the current time
+func NewFrame(d rfm69.Data) *Frame {
+	return &Frame{
+		From:   d.FromAddress,
+		To:     d.ToAddress,
+		Kind:   byte(d.Kind),
+		Rssi:   d.Rssi,
+		Ack:    d.RequestAck,
+		Hex:    hex.EncodeToString(d.Data),
+		Base64: base64.StdEncoding.EncodeToString(d.Data),
+		Time:   time.Now(),
+	}
+}
+
+// Request is the JSON form of a frame to send. The payload is given in hex
+// or base64, Get sends with ack and waits for the node to answer
+type Request struct {
+	ID     string `json:"id,omitempty"` // echoed in the result
+	Hex    string `json:"hex,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+	Ack    bool   `json:"ack,omitempty"`
+	Get    bool   `json:"get,omitempty"`
+}
+
+// Payload decodes the payload of the request
+func (r *Request) Payload() ([]byte, error) {
+	switch {
+	case r.Hex != "" && r.Base64 != "":
+		return nil, errors.New("both hex and base64 payload given")
+	case r.Hex != "":
+		return hex.DecodeString(r.Hex)
+	case r.Base64 != "":
+		return base64.StdEncoding.DecodeString(r.Base64)
+	}
+	return nil, nil
+}
+
+// Result reports the outcome of an acked send or a get
+type Result struct {
+	ID       string `json:"id,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Response *Frame `json:"response,omitempty"`
+}
@@ -0,0 +1,25 @@
+// Package gatewaytest runs routers on emulated radios for the gateway tests
+package gatewaytest
+
+import (
+	"testing"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+// Pair runs routers for nodes 1 and 2 on emulated radios in range of
+// each other until the test ends
+func Pair(t testing.TB) (*rfm69.Router, *rfm69.Router) {
+	t.Helper()
+	e1, e2 := rfm69.NewEmulator(1), rfm69.NewEmulator(2)
+	e1.OnTransmit = func(d rfm69.Data) { e2.Inject(d) }
+	e2.OnTransmit = func(d rfm69.Data) { e1.Inject(d) }
+	r1, r2 := rfm69.NewRouter(e1), rfm69.NewRouter(e2)
+	go r1.Run()
+	go r2.Run()
+	t.Cleanup(func() {
+		r1.Close()
+		r2.Close()
+	})
+	return r1, r2
+}
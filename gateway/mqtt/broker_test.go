@@ -0,0 +1,216 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	pktConnect     = 1
+	pktConnack     = 2
+	pktPublish     = 3
+	pktPuback      = 4
+	pktSubscribe   = 8
+	pktSuback      = 9
+	pktUnsubscribe = 10
+	pktUnsuback    = 11
+	pktPingreq     = 12
+	pktPingresp    = 13
+	pktDisconnect  = 14
+)
+
+// broker is just enough of an MQTT 3.1.1 broker to test against: clean
+// sessions only, messages are forwarded with QoS 0 and nothing is retained
+type broker struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	conns map[*brokerConn]bool
+}
+
+type brokerConn struct {
+	net.Conn
+	wmu  sync.Mutex
+	subs map[string]bool // guarded by broker.mu
+}
+
+// newBroker listens on a local port until the test ends
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{ln: ln, conns: make(map[*brokerConn]bool)}
+	go b.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		b.dropAll()
+	})
+	return b
+}
+
+func (b *broker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *broker) serve() {
+	for {
+		c, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		bc := &brokerConn{Conn: c, subs: make(map[string]bool)}
+		b.mu.Lock()
+		b.conns[bc] = true
+		b.mu.Unlock()
+		go b.handle(bc)
+	}
+}
+
+// dropAll closes every client connection, forgetting their subscriptions
+func (b *broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		c.Close()
+	}
+}
+
+func (b *broker) handle(c *brokerConn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		c.Close()
+	}()
+	r := bufio.NewReader(c)
+	for {
+		typ, flags, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case pktConnect:
+			c.send(pktConnack<<4, []byte{0, 0})
+		case pktSubscribe:
+			id, rest := body[:2], body[2:]
+			var granted []byte
+			for len(rest) > 0 {
+				topic, n := readString(rest)
+				rest = rest[n+1:]
+				b.mu.Lock()
+				c.subs[topic] = true
+				b.mu.Unlock()
+				granted = append(granted, 0)
+			}
+			c.send(pktSuback<<4, append(id, granted...))
+		case pktUnsubscribe:
+			c.send(pktUnsuback<<4, body[:2])
+		case pktPublish:
+			topic, n := readString(body)
+			payload := body[n:]
+			if qos := flags >> 1 & 3; qos > 0 {
+				c.send(pktPuback<<4, payload[:2])
+				payload = payload[2:]
+			}
+			b.forward(topic, payload)
+		case pktPingreq:
+			c.send(pktPingresp<<4, nil)
+		case pktDisconnect:
+			return
+		}
+	}
+}
+
+// forward publishes payload to every connection subscribed to topic
+func (b *broker) forward(topic string, payload []byte) {
+	msg := append(appendString(nil, topic), payload...)
+	b.mu.Lock()
+	var to []*brokerConn
+	for c := range b.conns {
+		for filter := range c.subs {
+			if matches(filter, topic) {
+				to = append(to, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, c := range to {
+		c.send(pktPublish<<4, msg)
+	}
+}
+
+func (c *brokerConn) send(header byte, body []byte) {
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		pkt = append(pkt, d)
+		if n == 0 {
+			break
+		}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.Write(append(pkt, body...))
+}
+
+func readPacket(r *bufio.Reader) (typ, flags byte, body []byte, err error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		n |= int(d&0x7F) << shift
+		shift += 7
+		if d&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return h >> 4, h & 0x0F, body, err
+}
+
+// readString decodes a length prefixed string, it returns the string and
+// the bytes it took
+func readString(b []byte) (string, int) {
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), 2 + n
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// matches tells whether topic matches filter with its + and # wildcards
+func matches(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
@@ -0,0 +1,253 @@
+// Package mqtt bridges a Router to an MQTT broker. Frames received from
+// node N of network W are published to rfm69/W/N/rx, messages published to
+// rfm69/W/N/tx are transmitted to node N and the outcome of acked sends and
+// requests is published to rfm69/W/N/result
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+	"github.com/charles-d-burton/rfm69-1/gateway"
+)
+
+// DefaultPrefix is the first level of every topic
+const DefaultPrefix = "rfm69"
+
+// PublishTimeout is how long publishing may wait for the broker
+const PublishTimeout = 10 * time.Second
+
+// Router is the part of rfm69.Router used by the gateway
+type Router interface {
+	Listen(ctx context.Context, nodes ...byte) (<-chan rfm69.Data, error)
+	Send(node byte, payload []byte) error
+	SendWithAck(node byte, payload []byte) error
+	Get(node byte, payload []byte) (rfm69.Data, error)
+}
+
+// Client is the part of an MQTT client used by the gateway
+type Client interface {
+	Publish(topic string, payload []byte) error
+	Subscribe(topic string, handle func(topic string, payload []byte)) error
+}
+
+// Gateway forwards frames between a Router and MQTT topics
+type Gateway struct {
+	router  Router
+	client  Client
+	network byte
+
+	// Prefix is the first topic level, DefaultPrefix if empty
+	Prefix string
+}
+
+// New creates a gateway for the given network
+func New(router Router, client Client, network byte) *Gateway {
+	return &Gateway{router: router, client: client, network: network, Prefix: DefaultPrefix}
+}
+
+// Start subscribes to the tx topics of all nodes and publishes the frames
+// the router receives until ctx is done. The router's handlers are left
+// alone
+func (g *Gateway) Start(ctx context.Context) error {
+	frames, err := g.router.Listen(ctx)
+	if err != nil {
+		return err
+	}
+	err = g.client.Subscribe(g.topic("+", "tx"), g.transmit)
+	if err != nil {
+		return err
+	}
+	// publishing waits for the broker, away from the router's Run loop
+	go func() {
+		for d := range frames {
+			g.received(d)
+		}
+	}()
+	return nil
+}
+
+func (g *Gateway) topic(node, leaf string) string {
+	prefix := g.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return fmt.Sprintf("%s/%d/%s/%s", prefix, g.network, node, leaf)
+}
+
+func nodeTopic(node byte) string {
+	return strconv.Itoa(int(node))
+}
+
+// received publishes a frame heard by the router, acks are the router's
+// business
+func (g *Gateway) received(d rfm69.Data) {
+	if d.SendAck {
+		return
+	}
+	g.publish(g.topic(nodeTopic(d.FromAddress), "rx"), gateway.NewFrame(d))
+}
+
+func (g *Gateway) publish(topic string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("mqtt: %v", err)
+		return
+	}
+	err = g.client.Publish(topic, raw)
+	if err != nil {
+		log.Printf("mqtt: publishing to %s: %v", topic, err)
+	}
+}
+
+// transmit handles a message on a tx topic
+func (g *Gateway) transmit(topic string, raw []byte) {
+	node, err := g.parseNode(topic)
+	if err != nil {
+		log.Printf("mqtt: %s: %v", topic, err)
+		return
+	}
+	var req gateway.Request
+	err = json.Unmarshal(raw, &req)
+	if err != nil {
+		log.Printf("mqtt: %s: %v", topic, err)
+		return
+	}
+	payload, err := req.Payload()
+	if err != nil {
+		log.Printf("mqtt: %s: %v", topic, err)
+		return
+	}
+
+	// acks and responses are delivered by the router's Run loop, which
+	// may be the one calling the handlers
+	go g.send(node, req, payload)
+}
+
+func (g *Gateway) send(node byte, req gateway.Request, payload []byte) {
+	var res *gateway.Result
+	switch {
+	case req.Get:
+		d, err := g.router.Get(node, payload)
+		res = &gateway.Result{ID: req.ID}
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Response = gateway.NewFrame(d)
+		}
+	case req.Ack:
+		err := g.router.SendWithAck(node, payload)
+		res = &gateway.Result{ID: req.ID}
+		if err != nil {
+			res.Error = err.Error()
+		}
+	default:
+		err := g.router.Send(node, payload)
+		if err != nil {
+			log.Printf("mqtt: sending to %d: %v", node, err)
+		}
+	}
+	if res != nil {
+		g.publish(g.topic(nodeTopic(node), "result"), res)
+	}
+}
+
+// parseNode extracts the node ID from prefix/network/node/tx
+func (g *Gateway) parseNode(topic string) (byte, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 2 {
+		return 0, errors.New("bad topic")
+	}
+	node, err := strconv.ParseUint(parts[len(parts)-2], 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("bad node %q", parts[len(parts)-2])
+	}
+	return byte(node), nil
+}
+
+// pahoClient adapts a paho client to Client
+type pahoClient struct {
+	client paho.Client
+	qos    byte
+
+	mu   sync.Mutex
+	subs map[string]paho.MessageHandler
+}
+
+// NewPahoClient wraps a connected paho client, publishing and
+// subscribing with the given QoS. A clean session loses the subscriptions
+// when the client reconnects, Dial restores them
+func NewPahoClient(client paho.Client, qos byte) Client {
+	return &pahoClient{client: client, qos: qos}
+}
+
+// Dial connects to broker, e.g. tcp://localhost:1883. Subscriptions are
+// renewed whenever the client reconnects
+func Dial(broker, clientID string) (Client, error) {
+	p := &pahoClient{qos: 1}
+	opts := paho.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(func(paho.Client) { p.resubscribe() })
+	p.client = paho.NewClient(opts)
+	t := p.client.Connect()
+	t.Wait()
+	if err := t.Error(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pahoClient) Publish(topic string, payload []byte) error {
+	t := p.client.Publish(topic, p.qos, false, payload)
+	if !t.WaitTimeout(PublishTimeout) {
+		return errors.New("timeout")
+	}
+	return t.Error()
+}
+
+func (p *pahoClient) Subscribe(topic string, handle func(string, []byte)) error {
+	h := func(_ paho.Client, m paho.Message) {
+		handle(m.Topic(), m.Payload())
+	}
+	p.mu.Lock()
+	if p.subs == nil {
+		p.subs = make(map[string]paho.MessageHandler)
+	}
+	p.subs[topic] = h
+	p.mu.Unlock()
+	return p.subscribe(topic, h)
+}
+
+func (p *pahoClient) subscribe(topic string, h paho.MessageHandler) error {
+	t := p.client.Subscribe(topic, p.qos, h)
+	t.Wait()
+	return t.Error()
+}
+
+// resubscribe renews every subscription after a connect
+func (p *pahoClient) resubscribe() {
+	p.mu.Lock()
+	subs := make(map[string]paho.MessageHandler, len(p.subs))
+	for topic, h := range p.subs {
+		subs[topic] = h
+	}
+	p.mu.Unlock()
+	for topic, h := range subs {
+		err := p.subscribe(topic, h)
+		if err != nil {
+			log.Printf("mqtt: resubscribing to %s: %v", topic, err)
+		}
+	}
+}
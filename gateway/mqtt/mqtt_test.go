@@ -0,0 +1,129 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+	"github.com/charles-d-burton/rfm69-1/gateway"
+	"github.com/charles-d-burton/rfm69-1/gateway/internal/gatewaytest"
+)
+
+// messages subscribes a test client to topic
+func messages(t *testing.T, c Client, topic string) <-chan []byte {
+	t.Helper()
+	msgs := make(chan []byte, 16)
+	err := c.Subscribe(topic, func(_ string, payload []byte) { msgs <- payload })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+func next(t *testing.T, msgs <-chan []byte, v interface{}) {
+	t.Helper()
+	select {
+	case raw := <-msgs:
+		err := json.Unmarshal(raw, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing published")
+	}
+}
+
+// gatewayOn starts a gateway for router on the broker and a client to
+// talk to it
+func gatewayOn(t *testing.T, b *broker, router Router) Client {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	gc, err := Dial(b.url(), "gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = New(router, gc, 100).Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err := Dial(b.url(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tc
+}
+
+func TestGatewayPublishesFrames(t *testing.T) {
+	r1, r2 := gatewaytest.Pair(t)
+	handled := make(chan rfm69.Data, 1)
+	r1.Handle(2, func(d rfm69.Data) { handled <- d })
+	tc := gatewayOn(t, newBroker(t), r1)
+	rx := messages(t, tc, "rfm69/100/2/rx")
+
+	err := r2.SendWithAck(1, []byte{0xca, 0xfe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f gateway.Frame
+	next(t, rx, &f)
+	if f.From != 2 || f.Hex != "cafe" || !f.Ack {
+		t.Fatalf("published %+v", f)
+	}
+	// the gateway listens next to the handlers instead of replacing them
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+}
+
+func TestGatewayGet(t *testing.T) {
+	r1, r2 := gatewaytest.Pair(t)
+	r2.Handle(1, func(d rfm69.Data) {
+		go r2.Send(1, append([]byte("re "), d.Data...))
+	})
+	tc := gatewayOn(t, newBroker(t), r1)
+	results := messages(t, tc, "rfm69/100/2/result")
+
+	err := tc.Publish("rfm69/100/2/tx", []byte(`{"id": "7", "hex": "6869", "get": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res gateway.Result
+	next(t, results, &res)
+	if res.ID != "7" || res.Error != "" || res.Response == nil || res.Response.Hex != fmt.Sprintf("%x", "re hi") {
+		t.Fatalf("result %+v", res)
+	}
+}
+
+func TestGatewayResubscribes(t *testing.T) {
+	r1, r2 := gatewaytest.Pair(t)
+	got := make(chan rfm69.Data, 4)
+	r2.Handle(1, func(d rfm69.Data) { got <- d })
+	b := newBroker(t)
+	tc := gatewayOn(t, b, r1)
+
+	// a clean session loses the subscriptions with the connection
+	b.dropAll()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := tc.Publish("rfm69/100/2/tx", []byte(`{"hex": "01"}`))
+		if err == nil {
+			select {
+			case d := <-got:
+				if string(d.Data) != "\x01" {
+					t.Fatalf("received %+v", d)
+				}
+				return
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tx topic not resubscribed after reconnecting")
+		}
+	}
+}
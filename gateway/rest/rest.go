@@ -0,0 +1,219 @@
+// Package rest exposes a Router over HTTP:
+//
+//	POST /nodes/{id}/send  send a gateway.Request, acked if "ack" is set
+//	POST /nodes/{id}/get   send a gateway.Request and return the answer
+//	GET  /nodes            nodes heard from
+//	GET  /events           Server-Sent Events stream of received frames
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+	"github.com/charles-d-burton/rfm69-1/gateway"
+)
+
+// Router is the part of rfm69.Router used by the server
+type Router interface {
+	Listen(ctx context.Context, nodes ...byte) (<-chan rfm69.Data, error)
+	Send(node byte, payload []byte) error
+	SendWithAck(node byte, payload []byte) error
+	Get(node byte, payload []byte) (rfm69.Data, error)
+	Nodes() []rfm69.NodeInfo
+}
+
+// Node is the JSON form of rfm69.NodeInfo
+type Node struct {
+	ID        byte      `json:"id"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Packets   uint64    `json:"packets"`
+	Rssi      int       `json:"rssi"` // of the last frame
+	History   []int     `json:"rssi_history"`
+	Firmware  string    `json:"firmware,omitempty"`
+	Online    bool      `json:"online"`
+}
+
+// Server is an http.Handler for a Router
+type Server struct {
+	router Router
+}
+
+// New creates a server, event streams listen to the router alongside its
+// handlers
+func New(router Router) *Server {
+	return &Server{router: router}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.Trim(req.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "nodes":
+		s.only(w, req, http.MethodGet, s.nodes)
+	case path == "events":
+		s.only(w, req, http.MethodGet, s.events)
+	case len(parts) == 3 && parts[0] == "nodes" && parts[2] == "send":
+		s.only(w, req, http.MethodPost, func(w http.ResponseWriter, req *http.Request) {
+			s.send(w, req, parts[1], false)
+		})
+	case len(parts) == 3 && parts[0] == "nodes" && parts[2] == "get":
+		s.only(w, req, http.MethodPost, func(w http.ResponseWriter, req *http.Request) {
+			s.send(w, req, parts[1], true)
+		})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (s *Server) only(w http.ResponseWriter, req *http.Request, method string, h http.HandlerFunc) {
+	if req.Method != method {
+		w.Header().Set("Allow", method)
+		httpError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	h(w, req)
+}
+
+func (s *Server) nodes(w http.ResponseWriter, req *http.Request) {
+	list := s.router.Nodes()
+	nodes := make([]Node, 0, len(list))
+	for _, n := range list {
+		node := Node{
+			ID:        n.ID,
+			FirstSeen: n.FirstSeen,
+			LastSeen:  n.LastSeen,
+			Packets:   n.Packets,
+			History:   n.Rssi,
+			Firmware:  n.Firmware,
+			Online:    n.Online,
+		}
+		if len(n.Rssi) > 0 {
+			node.Rssi = n.Rssi[len(n.Rssi)-1]
+		}
+		nodes = append(nodes, node)
+	}
+	writeJSON(w, http.StatusOK, nodes)
+}
+
+// send transmits the request body to node, get waits for the answer
+func (s *Server) send(w http.ResponseWriter, req *http.Request, id string, get bool) {
+	node, err := strconv.ParseUint(id, 10, 8)
+	if err != nil {
+		httpError(w, http.StatusNotFound, fmt.Errorf("bad node %q", id))
+		return
+	}
+	var r gateway.Request
+	err = json.NewDecoder(req.Body).Decode(&r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	payload, err := r.Payload()
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := &gateway.Result{ID: r.ID}
+	ctx := req.Context()
+	cs, traced := s.router.(rfm69.ContextSender)
+	switch {
+	case get && traced:
+		var d rfm69.Data
+		d, err = cs.GetContext(ctx, byte(node), payload)
+		if err == nil {
+			res.Response = gateway.NewFrame(d)
+		}
+	case get:
+		var d rfm69.Data
+		d, err = s.router.Get(byte(node), payload)
+		if err == nil {
+			res.Response = gateway.NewFrame(d)
+		}
+	case r.Ack && traced:
+		err = cs.SendWithAckContext(ctx, byte(node), payload)
+	case r.Ack:
+		err = s.router.SendWithAck(byte(node), payload)
+	case traced:
+		err = cs.SendContext(ctx, byte(node), payload)
+	default:
+		err = s.router.Send(byte(node), payload)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		writeJSON(w, sendStatus(err), res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// sendStatus maps a send error to an HTTP status, requests the radio
+// can't carry are the client's fault, everything else the node's
+func sendStatus(err error) int {
+	switch {
+	case errors.Is(err, rfm69.ErrPayloadSize):
+		return http.StatusBadRequest
+	case errors.Is(err, rfm69.ErrClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusGatewayTimeout
+}
+
+// events streams received frames until the client goes away
+func (s *Server) events(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	frames, err := s.router.Listen(req.Context())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	// closed once the client goes away
+	for d := range frames {
+		if d.SendAck {
+			// answered by the router, not news
+			continue
+		}
+		raw, err := json.Marshal(gateway.NewFrame(d))
+		if err != nil {
+			log.Printf("rest: %v", err)
+			continue
+		}
+		_, err = fmt.Fprintf(w, "event: rx\ndata: %s\n\n", raw)
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("rest: %v", err)
+	}
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+	"github.com/charles-d-burton/rfm69-1/gateway"
+	"github.com/charles-d-burton/rfm69-1/gateway/internal/gatewaytest"
+)
+
+// post sends body to the server and decodes the result
+func post(t *testing.T, srv *httptest.Server, path, body string) (int, gateway.Result) {
+	t.Helper()
+	resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var res gateway.Result
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, res
+}
+
+func TestSendStatus(t *testing.T) {
+	r1, r2 := gatewaytest.Pair(t)
+	got := make(chan rfm69.Data, 1)
+	r2.Handle(1, func(d rfm69.Data) { got <- d })
+	srv := httptest.NewServer(New(r1))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name, path, body string
+		status           int
+	}{
+		{"acked", "/nodes/2/send", `{"hex": "0102", "ack": true}`, http.StatusOK},
+		{"no ack", "/nodes/9/send", `{"hex": "0102", "ack": true}`, http.StatusGatewayTimeout},
+		{"too long", "/nodes/2/send", `{"hex": "` + strings.Repeat("00", rfm69.MaxLongDataLen+1) + `"}`, http.StatusBadRequest},
+		{"bad payload", "/nodes/2/send", `{"hex": "zz"}`, http.StatusBadRequest},
+	} {
+		if status, res := post(t, srv, tc.path, tc.body); status != tc.status {
+			t.Errorf("%s: status %d (%s), want %d", tc.name, status, res.Error, tc.status)
+		}
+	}
+	select {
+	case d := <-got:
+		if string(d.Data) != "\x01\x02" {
+			t.Fatalf("received %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing received")
+	}
+}
+
+func TestGet(t *testing.T) {
+	r1, r2 := gatewaytest.Pair(t)
+	r2.Handle(1, func(d rfm69.Data) {
+		go r2.Send(1, []byte("pong"))
+	})
+	srv := httptest.NewServer(New(r1))
+	defer srv.Close()
+
+	status, res := post(t, srv, "/nodes/2/get", `{"id": "1", "base64": "cGluZw=="}`)
+	if status != http.StatusOK || res.ID != "1" || res.Response == nil || res.Response.Base64 != "cG9uZw==" {
+		t.Fatalf("status %d, result %+v", status, res)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	r1, r2 := gatewaytest.Pair(t)
+	handled := make(chan rfm69.Data, 1)
+	r1.Handle(2, func(d rfm69.Data) { handled <- d })
+	srv := httptest.NewServer(New(r1))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	err = r2.SendWithAck(1, []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		data := strings.TrimPrefix(lines.Text(), "data: ")
+		if data == lines.Text() {
+			continue
+		}
+		var f gateway.Frame
+		err := json.Unmarshal([]byte(data), &f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.From != 2 || f.Hex != "6869" {
+			t.Fatalf("event %+v", f)
+		}
+		break
+	}
+	// the stream listens next to the handlers instead of replacing them
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+}
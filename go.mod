@@ -4,8 +4,17 @@ go 1.18
 
 require (
 	github.com/davecheney/gpio v0.0.0-20160912024957-a6de66e7e470
+	github.com/eclipse/paho.mqtt.golang v1.4.3
 	github.com/fulr/spidev v0.0.0-20150210165549-524e13e3fac2
 	periph.io/x/conn/v3 v3.6.10
+	periph.io/x/host/v3 v3.7.2
 )
 
 require periph.io/x/periph v3.6.8+incompatible
+
+require (
+	github.com/gorilla/websocket v1.5.0 // indirect
+	github.com/jonboulle/clockwork v0.2.2 // indirect
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+)
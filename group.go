@@ -0,0 +1,86 @@
+package rfm69
+
+import (
+	"context"
+	"sync"
+)
+
+// groupSet holds the multicast groups this node subscribed to
+type groupSet struct {
+	mu     sync.Mutex
+	groups map[byte]bool
+}
+
+func (g *groupSet) set(group byte, on bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.groups == nil {
+		g.groups = make(map[byte]bool)
+	}
+	if on {
+		g.groups[group] = true
+	} else {
+		delete(g.groups, group)
+	}
+}
+
+func (g *groupSet) has(group byte) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.groups[group]
+}
+
+// Subscribe delivers frames multicast to group to the registered handlers.
+// Group IDs share the address byte with node IDs, nodes not knowing
+// KindGroup, like stock LowPowerLab ones, take a multicast to group N for a
+// frame to node N. Pick group IDs no node of the network uses
+func (r *Router) Subscribe(group byte) {
+	r.groups.set(group, true)
+}
+
+// Unsubscribe stops delivery of frames multicast to group
+func (r *Router) Unsubscribe(group byte) {
+	r.groups.set(group, false)
+}
+
+// Broadcast sends payload to every node, broadcasts are never acked
+func (r *Router) Broadcast(payload []byte) error {
+	return r.Send(BroadcastAddress, payload)
+}
+
+// Multicast sends payload to every node subscribed to group, multicasts are
+// never acked. See Subscribe on choosing group IDs
+func (r *Router) Multicast(group byte, payload []byte) error {
+	return r.MulticastContext(context.Background(), group, payload)
+}
+
+// MulticastContext is Multicast, giving up when ctx is done before the frame
+// is queued
+func (r *Router) MulticastContext(ctx context.Context, group byte, payload []byte) error {
+	frame, _, err := r.frame(group, KindGroup, false, payload)
+	if err != nil {
+		return err
+	}
+	return r.queue(ctx, frame)
+}
+
+// accepts tells whether a received frame is delivered on this node, in
+// promiscuous mode every frame is
+func (r *Router) accepts(data *Data) bool {
+	return r.Promiscuous || r.addressed(data)
+}
+
+// addressed tells whether a received frame is for this node: addressed to
+// it, broadcast, or multicast to a subscribed group
+func (r *Router) addressed(data *Data) bool {
+	if data.Kind == KindGroup {
+		return r.groups.has(data.ToAddress)
+	}
+	return data.ToAddress == r.Radio.Address() || data.ToAddress == BroadcastAddress
+}
+
+// wantsAck tells whether data must be acked by this node, broadcasts and
+// multicasts never are
+func (r *Router) wantsAck(data *Data) bool {
+	return data.RequestAck && data.ToAddress == r.Radio.Address() && data.Kind != KindGroup
+}
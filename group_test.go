@@ -0,0 +1,77 @@
+package rfm69
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMulticastReachesSubscribers(t *testing.T) {
+	a := newAir(t, 1, 2, 3)
+	h2, got2 := collect()
+	h3, got3 := collect()
+	a.router(2).Handle(1, h2)
+	a.router(3).Handle(1, h3)
+	a.router(2).Subscribe(7)
+
+	err := a.router(1).Multicast(7, []byte("group"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, got2); d.ToAddress != 7 || d.Kind != KindGroup || string(d.Data) != "group" {
+		t.Fatalf("received %+v", d)
+	}
+	nothing(t, got3)
+
+	a.router(2).Unsubscribe(7)
+	err = a.router(1).Multicast(7, []byte("group"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nothing(t, got2)
+}
+
+func TestMulticastContextCanceled(t *testing.T) {
+	// not running, nothing drains the transmit queue
+	r := NewRouter(NewEmulator(1))
+	for i := 0; i < cap(r.tx); i++ {
+		err := r.Multicast(7, []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := r.MulticastContext(ctx, 7, []byte("full"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("multicast to a full queue: %v", err)
+	}
+}
+
+func TestBroadcastsNeverAcked(t *testing.T) {
+	a := newAir(t, 1, 2, 3)
+	h2, got2 := collect()
+	h3, got3 := collect()
+	a.router(2).Handle(1, h2)
+	a.router(3).Handle(1, h3)
+	sent := make(chan Data, 4)
+	a.onTransmit(2, func(d Data) { sent <- d })
+
+	err := a.router(1).Broadcast([]byte("all"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, got2)
+	receive(t, got3)
+
+	// even if the sender asked for one
+	a.inject(2, Data{FromAddress: 1, ToAddress: BroadcastAddress, RequestAck: true})
+	a.inject(2, Data{FromAddress: 1, ToAddress: 2, Kind: KindGroup, RequestAck: true})
+	receive(t, got2)
+	select {
+	case d := <-sent:
+		t.Fatalf("node 2 answered with %+v", d)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -1,9 +1,10 @@
 package rfm69
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"periph.io/x/conn/v3/gpio"
@@ -13,23 +14,87 @@ import (
 )
 
 type RFMOptions struct {
+	SPIBus        string // SPI port name, the first one available if empty
 	NodeID        byte
 	NetworkID     byte
 	IsRfm69HCW    bool
-	EncryptionKey string
+	EncryptionKey KeyString // 16 raw bytes, 32 hex digits or base64
+	Keys          *KeyStore // scheduled keys, replaces EncryptionKey
 	ResetPin      gpio.PinOut
 	IrqPin        gpio.PinIn
+	Dio1Pin       gpio.PinIn // DCLK in continuous mode
+	Dio2Pin       gpio.PinIO // DATA in continuous mode
+	Dio3Pin       gpio.PinIn // SyncAddress when receiving, times the FEI and long frames
+	Dio4Pin       gpio.PinIn
+	Dio5Pin       gpio.PinIn
+	PollInterval  time.Duration // interrupt flag polling when IrqPin is nil
+
+	// CSMA waits for a quiet channel before sending, up to a second,
+	// instead of transmitting right away
+	CSMA bool
 }
 
 // Router manages sending and receiving of commands / data
 type Router struct {
-	Port      spi.PortCloser
-	handlers  map[byte]Handle
-	responses map[byte]chan Data
-
+	Port     spi.PortCloser
+	handlers map[byte]Handle
+	pending  pendingTable
+
+	defaultHandler Handle
+	middleware     []Middleware
+	kindHandlers   map[Kind]Handle // protocol layers on top of the Router
+	relay          *Relay
+
+	// Promiscuous disables the address check, every frame heard is
+	// delivered to the handlers including those addressed to other nodes
+	// and the hellos, acks and protocol frames the Router consumes
+	Promiscuous bool
+
+	// Radio is the transceiver frames are sent and received with
+	Radio Radio
+	// RFM is the device opened by Init, nil for routers created with
+	// NewRouter
 	RFM *Device
 
-	tx chan *Data
+	// Framer, if set, seals outgoing and opens incoming payloads
+	Framer Framer
+
+	// Tracer, if set, traces Send, SendWithAck and Get
+	Tracer Tracer
+
+	// NodeTimeout is how long a node may stay silent before it is
+	// reported offline, DefaultNodeTimeout if zero
+	NodeTimeout   time.Duration
+	OnNodeOnline  NodeHandler
+	OnNodeOffline NodeHandler
+	nodes         nodeTable
+
+	// OnLinkDegraded is called when the packet error rate to a node rises
+	// above MaxPER or the mean RSSI from it drops below MinRssi, zero
+	// disables either check
+	OnLinkDegraded LinkHandler
+	MaxPER         float64
+	MinRssi        int
+	links          linkTable
+
+	// Sequenced prefixes every data frame with a sequence number, counted
+	// per destination and sealed with the payload if there is a Framer.
+	// Retries are delivered once and acks echo the sequence. All nodes of
+	// the network must agree on it
+	Sequenced bool
+	// DedupWindow is how long a sequence number is remembered per sender,
+	// DefaultDedupWindow if zero
+	DedupWindow time.Duration
+	seqs        seqCounter
+	dedup       dedupTable
+	groups      groupSet
+	subs        subscriptions
+	metrics     metricsRef
+
+	tx      chan *Data
+	flush   chan chan struct{} // Close asks Run to transmit what is queued
+	runMu   sync.Mutex
+	running bool
 }
 
 // Handle is a function that can be registered to handle an rfm69 message
@@ -44,18 +109,17 @@ func (r *Router) Handle(node byte, handle Handle) {
 	r.handlers[node] = handle
 }
 
-// Init initializes the connection to the rfm69 module
-func Init(options *RFMOptions) (*Router, error) {
-	var err error
-	r := new(Router)
-
-	// Use spireg SPI port registry to find the first available SPI bus.
-	r.Port, err = spireg.Open("")
-	fmt.Println("spi port open")
-	if err != nil {
-		return nil, err
+// NewRouter creates a router on top of radio
+func NewRouter(radio Radio) *Router {
+	return &Router{
+		Radio: radio,
+		tx:    make(chan *Data, 5),
+		flush: make(chan chan struct{}),
 	}
+}
 
+// Init initializes the connection to the rfm69 module
+func Init(options *RFMOptions) (*Router, error) {
 	if options == nil {
 		options = new(RFMOptions)
 		options.IsRfm69HCW = true
@@ -65,193 +129,325 @@ func Init(options *RFMOptions) (*Router, error) {
 		options.IrqPin = gpioreg.ByName("GPIO25")
 	}
 
-	fmt.Println("setting up device")
-	r.RFM, err = NewDevice(r.Port, options)
-
+	// Use spireg SPI port registry to find the SPI bus.
+	port, err := spireg.Open(options.SPIBus)
 	if err != nil {
 		return nil, err
 	}
 
-    fmt.Println("setting encryption key")
-	err = r.RFM.Encrypt([]byte(options.EncryptionKey))
+	rfm, err := NewDevice(port, options)
+
 	if err != nil {
 		return nil, err
 	}
 
-	r.tx = make(chan *Data, 5)
+	if options.EncryptionKey != "" && options.Keys == nil {
+		key, err := ParseKey(string(options.EncryptionKey))
+		if err != nil {
+			return nil, err
+		}
+		err = rfm.Encrypt(key[:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := NewRouter(rfm)
+	r.Port = port
+	r.RFM = rfm
 
 	return r, nil
 }
 
-// Run the rfm98 event watcher
+// Run the rfm98 event watcher, it returns when the radio is closed
 func (r *Router) Run() {
-	irq := make(chan bool)
-	r.RFM.WaitForIRQ(irq)
+	rx := r.Radio.Receive()
+	r.setRunning(true)
+	defer r.setRunning(false)
 
-	err := r.RFM.SetMode(RF_OPMODE_RECEIVER)
-	if err != nil {
-		log.Print(err)
-		return
-	}
-	defer r.RFM.SetMode(RF_OPMODE_STANDBY)
+	expire := time.NewTicker(time.Second)
+	defer expire.Stop()
 
 	for {
 		select {
+		case <-expire.C:
+			r.expireNodes()
 		case dataToTransmit := <-r.tx:
-			// TODO: can send?
-			r.RFM.readWriteReg(REG_PACKETCONFIG2, 0xFB, RF_PACKET2_RXRESTART) // avoid RX deadlocks
-			err = r.RFM.SetModeAndWait(RF_OPMODE_STANDBY)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.RFM.writeReg(REG_DIOMAPPING1, RF_DIOMAPPING1_DIO0_00)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.RFM.writeFifo(dataToTransmit)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.RFM.SetMode(RF_OPMODE_TRANSMITTER)
-			if err != nil {
-				log.Fatal(err)
+			r.transmit(dataToTransmit)
+		case done := <-r.flush:
+			r.transmitQueued()
+			close(done)
+		case data, ok := <-rx:
+			if !ok {
+				return
 			}
+			r.dispatch(data)
+		}
+	}
+}
 
-			<-irq
+func (r *Router) setRunning(running bool) {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+	r.running = running
+}
 
-			err = r.RFM.SetModeAndWait(RF_OPMODE_STANDBY)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.RFM.writeReg(REG_DIOMAPPING1, RF_DIOMAPPING1_DIO0_01)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.RFM.SetMode(RF_OPMODE_RECEIVER)
-			if err != nil {
-				log.Fatal(err)
-			}
-		case interrupt := <-irq:
-			if interrupt {
-				if r.RFM.mode != RF_OPMODE_RECEIVER {
-					continue
-				}
-				flags, err := r.RFM.readReg(REG_IRQFLAGS2)
-				if err != nil {
-					return
-				}
-				if flags&RF_IRQFLAGS2_PAYLOADREADY == 0 {
-					continue
-				}
-				data, err := r.RFM.readFifo()
-				if err != nil {
-					log.Print(err)
-					return
-				}
-				err = r.RFM.SetMode(RF_OPMODE_RECEIVER)
-				if err != nil {
-					log.Fatal(err)
-				}
-				if data.ToAddress != r.RFM.Config.NodeID {
-					break
-				}
-				if data.ToAddress != 255 && data.RequestAck {
-					r.tx <- data.ToAck()
-				}
+func (r *Router) transmit(d *Data) {
+	err := r.Radio.Transmit(d)
+	if err != nil {
+		log.Println(err)
+		r.stats().LoopError(err)
+	}
+}
 
-				// check if
-				// 1. we are waiting for a response from this node
-				// 2. we have a handler for this node otherwise
+// transmitQueued hands the frames queued so far to the radio
+func (r *Router) transmitQueued() {
+	for {
+		select {
+		case d := <-r.tx:
+			r.transmit(d)
+		default:
+			return
+		}
+	}
+}
 
-				if c, ok := r.responses[data.FromAddress]; ok {
-					c <- data
-				} else if h, ok := r.handlers[data.FromAddress]; ok {
-					h(data)
-				}
+// dispatch acks a received frame and hands it to the waiting request or
+// the handler registered for its sender
+func (r *Router) dispatch(data Data) {
+	if r.Framer == nil {
+		r.trackNode(&data)
+	}
+	if data.Kind == KindRelay {
+		r.relayed(data)
+		if data.ToAddress != r.Radio.Address() {
+			r.sniff(data)
+		}
+		return
+	}
+	if r.relay != nil && r.relay.overheard(data) {
+		return
+	}
+	if !r.accepts(&data) {
+		return
+	}
+	sequenced := r.Sequenced && sequencedKind(data.Kind) && !data.SendAck
+	if r.Framer != nil && r.addressed(&data) {
+		// unsealed frames, empty ones included, are rejected. Frames for
+		// other nodes are delivered sealed in promiscuous mode, opening
+		// them would advance the sender's replay counter
+		payload, err := r.Framer.Open(data.FromAddress, data.ToAddress, ctlByte(&data), data.Data)
+		if errors.Is(err, ErrReplay) && r.wantsAck(&data) {
+			// a retry whose ack got lost, ack again but deliver once
+			if !sequenced {
+				r.ack(data, 0, false)
+			} else if len(payload) > 0 {
+				r.ack(data, payload[0], true)
 			}
+			return
 		}
+		if err != nil {
+			log.Printf("dropping frame from %d: %v", data.FromAddress, err)
+			return
+		}
+		data.Data = payload
+		// with a Framer only authentic frames count as signs of life
+		r.trackNode(&data)
+	} else if r.Framer != nil {
+		// the sequence number is sealed too
+		sequenced = false
+	}
+	var seq byte
+	sequenced = sequenced && len(data.Data) > 0
+	if sequenced {
+		seq, data.Data = data.Data[0], data.Data[1:]
+	}
+	r.linkReceived(&data)
+	if r.wantsAck(&data) {
+		r.ack(data, seq, sequenced)
+	}
+	if sequenced && r.dedup.duplicate(data.FromAddress, data.ToAddress, seq, r.DedupWindow, time.Now()) {
+		return
+	}
+	if data.Kind == KindHello {
+		r.nodes.hello(data.FromAddress, string(data.Data))
+		r.sniff(data)
+		return
+	}
+	if h, ok := r.kindHandlers[data.Kind]; ok && !data.SendAck {
+		h(data)
+		r.sniff(data)
+		return
+	}
+
+	r.subs.publish(data)
+
+	// responses go to the request waiting for them, everything else to the
+	// handler of the sender or the default handler
+	unicast := data.ToAddress == r.Radio.Address() && data.Kind == KindData
+	if unicast && r.pending.deliver(data) {
+		return
+	}
+	if data.SendAck {
+		// acks no request waits for any more are dropped, unless sniffing
+		r.sniff(data)
+		return
+	}
+	r.deliver(data)
+}
+
+// deliver hands data through the middleware to the handler registered for
+// its sender, or the default handler
+func (r *Router) deliver(data Data) {
+	if h, ok := r.handlers[data.FromAddress]; ok {
+		r.wrap(h)(data)
+	} else if r.defaultHandler != nil {
+		r.wrap(r.defaultHandler)(data)
+	}
+}
+
+// sniff delivers frames consumed by the Router itself in promiscuous mode,
+// so the handlers see every frame heard
+func (r *Router) sniff(data Data) {
+	if r.Promiscuous {
+		r.deliver(data)
 	}
 }
 
 // Send data to a node
 func (r *Router) Send(nodeID byte, payload []byte) error {
-	_, err := r.request(nodeID, payload, false, 0, 0, false, 0)
+	return r.SendContext(context.Background(), nodeID, payload)
+}
+
+// SendContext sends data to a node, ctx carries the trace
+func (r *Router) SendContext(ctx context.Context, nodeID byte, payload []byte) (err error) {
+	ctx, span := r.startSpan(ctx, "send", nodeID, len(payload))
+	defer func() { span.End(err) }()
+	_, err = r.request(ctx, span, nodeID, KindData, payload, false, 0, 0, false, 0)
 	return err
 }
 
 // SendWithAck sends data to a node with ack
 func (r *Router) SendWithAck(nodeID byte, payload []byte) error {
-	_, err := r.request(nodeID, payload, true, 3, 40, false, 0)
+	return r.SendWithAckContext(context.Background(), nodeID, payload)
+}
+
+// SendWithAckContext sends data to a node with ack, giving up when ctx is
+// done
+func (r *Router) SendWithAckContext(ctx context.Context, nodeID byte, payload []byte) (err error) {
+	ctx, span := r.startSpan(ctx, "send_with_ack", nodeID, len(payload))
+	defer func() { span.End(err) }()
+	_, err = r.request(ctx, span, nodeID, KindData, payload, true, 3, 40, false, 0)
 	return err
 }
 
 // Get data from a node (send request with ack and wait for response)
 func (r *Router) Get(nodeID byte, payload []byte) (Data, error) {
-	return r.request(nodeID, payload, true, 3, 40, true, 3000)
+	return r.GetContext(context.Background(), nodeID, payload)
+}
+
+// GetContext gets data from a node, giving up when ctx is done
+func (r *Router) GetContext(ctx context.Context, nodeID byte, payload []byte) (d Data, err error) {
+	ctx, span := r.startSpan(ctx, "get", nodeID, len(payload))
+	defer func() { span.End(err) }()
+	return r.request(ctx, span, nodeID, KindData, payload, true, 3, 40, true, 3000)
 }
 
 // Internal function to send data and handle responses
 // acktime and datatime are in milliseconds
-func (r *Router) request(nodeID byte, payload []byte, ack bool, retries int, acktime uint16, getdata bool, datatime uint16) (Data, error) {
-	resp := make(chan Data, 1)
+func (r *Router) request(ctx context.Context, span Span, nodeID byte, kind Kind, payload []byte, ack bool, retries int, acktime uint16, getdata bool, datatime uint16) (Data, error) {
+	frame, seq, err := r.frame(nodeID, kind, ack, payload)
+	if err != nil {
+		return Data{}, err
+	}
 
-	if r.responses == nil {
-		r.responses = make(map[byte]chan Data)
+	var req *pendingRequest
+	if ack || getdata {
+		req = r.pending.add(nodeID, seq, r.Sequenced && sequencedKind(kind), ack, getdata)
+		defer r.pending.remove(req)
 	}
-	r.responses[nodeID] = resp
 
 	if ack {
 	loop:
 		for i := 1; i <= retries; i++ {
-			r.tx <- &Data{
-				ToAddress:  nodeID,
-				Data:       payload,
-				RequestAck: ack,
+			if i > 1 {
+				r.stats().Retry(nodeID)
 			}
-			if ack {
-				select {
-				case d := <-resp:
-					if len(d.Data) > 0 {
-						return Data{}, errors.New("invalid ack")
-					}
-					break loop
-				case <-time.After(time.Millisecond * time.Duration(acktime)):
-					if i == retries {
-						return Data{}, errors.New("no ack response")
+			span.Attempt(i)
+			err = r.queue(ctx, frame)
+			if err != nil {
+				return Data{}, err
+			}
+			select {
+			case d := <-req.c:
+				span.Ack(d.Rssi)
+				if nodeID != BroadcastAddress {
+					r.linkSent(nodeID, i, true)
+				}
+				break loop
+			case <-time.After(time.Millisecond * time.Duration(acktime)):
+				if i == retries {
+					r.stats().AckTimeout(nodeID)
+					if nodeID != BroadcastAddress {
+						r.linkSent(nodeID, i, false)
 					}
+					return Data{}, errors.New("no ack response")
 				}
+			case <-ctx.Done():
+				return Data{}, ctx.Err()
 			}
 		}
 	} else {
-		r.tx <- &Data{
-			ToAddress:  nodeID,
-			Data:       payload,
-			RequestAck: ack,
+		span.Attempt(1)
+		err = r.queue(ctx, frame)
+		if err != nil {
+			return Data{}, err
 		}
 	}
 
+	if !getdata {
+		return Data{}, nil
+	}
 	select {
-	case d := <-resp:
-		delete(r.responses, nodeID)
-		if getdata {
-			return d, nil
-		} else {
-			return Data{}, nil
-		}
+	case d := <-req.c:
+		span.Data(d.Rssi, len(d.Data))
+		return d, nil
 	case <-time.After(time.Millisecond * time.Duration(datatime)):
-		delete(r.responses, nodeID)
-		if getdata {
-			return Data{}, errors.New("no data response")
-		} else {
-			return Data{}, nil
-		}
+		return Data{}, errors.New("no data response")
+	case <-ctx.Done():
+		return Data{}, ctx.Err()
+	}
+}
+
+// queue hands a frame to the Run loop for transmission
+func (r *Router) queue(ctx context.Context, d *Data) error {
+	select {
+	case r.tx <- d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-// Close connection to the rfm69 module
+// Close connection to the rfm69 module. Frames queued by Send are
+// transmitted first, through Run if it is running so they keep their order.
+// It must not be called from a handler
 func (r *Router) Close() error {
-	if err := r.Port.Close(); err != nil {
-		log.Fatal(err)
+	r.runMu.Lock()
+	running := r.running
+	r.runMu.Unlock()
+	if running {
+		done := make(chan struct{})
+		r.flush <- done
+		<-done
+	} else {
+		r.transmitQueued()
 	}
-	return r.RFM.Close()
+	err := r.Radio.Close()
+	if r.Port != nil {
+		if err := r.Port.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return err
 }
@@ -0,0 +1,183 @@
+package rfm69
+
+import (
+	"testing"
+	"time"
+)
+
+// secured gives the router a SecureFramer with testKey
+func secured(t *testing.T) func(*Router) {
+	return func(r *Router) {
+		f, err := NewSecureFramer(testKey[:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Framer = f
+	}
+}
+
+func TestSendWithAck(t *testing.T) {
+	a := newAir(t, 1, 2)
+	h, got := collect()
+	a.router(2).Handle(1, h)
+
+	err := a.router(1).SendWithAck(2, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, got); string(d.Data) != "hello" || d.FromAddress != 1 {
+		t.Fatalf("received %+v", d)
+	}
+	if err := a.router(1).SendWithAck(9, nil); err == nil {
+		t.Fatal("send to a missing node acked")
+	}
+}
+
+func TestSequencedFramedAcks(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(*Router)
+	}{
+		{"framed", secured(t)},
+		{"sequenced", func(r *Router) { r.Sequenced = true }},
+		{"sequenced framed", func(r *Router) {
+			secured(t)(r)
+			r.Sequenced = true
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAirWith(t, tc.setup, 1, 2)
+			h, got := collect()
+			a.router(2).Handle(1, h)
+
+			for i := 0; i < 3; i++ {
+				err := a.router(1).SendWithAck(2, []byte{byte(i)})
+				if err != nil {
+					t.Fatalf("send %d: %v", i, err)
+				}
+				if d := receive(t, got); len(d.Data) != 1 || d.Data[0] != byte(i) {
+					t.Fatalf("send %d received %+v", i, d)
+				}
+			}
+		})
+	}
+}
+func TestFramedAckForged(t *testing.T) {
+	a := newAirWith(t, secured(t), 1, 2)
+	// node 2 drops everything, a forged unsealed ack must not satisfy 1
+	a.cut(1, 2)
+	a.onTransmit(1, func(d Data) {
+		if d.RequestAck {
+			a.inject(1, Data{FromAddress: 2, ToAddress: 1, SendAck: true})
+		}
+	})
+	if err := a.router(1).SendWithAck(2, []byte("x")); err == nil {
+		t.Fatal("unsealed ack accepted")
+	}
+}
+
+// inFlight returns the number of requests r is waiting on
+func inFlight(r *Router) int {
+	r.pending.mu.Lock()
+	defer r.pending.mu.Unlock()
+	return len(r.pending.reqs)
+}
+
+func TestGetResponse(t *testing.T) {
+	a := newAir(t, 1, 2)
+	a.router(2).Handle(1, func(d Data) {
+		go a.router(2).Send(1, append([]byte("re: "), d.Data...))
+	})
+
+	d, err := a.router(1).Get(2, []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d.Data) != "re: ping" || d.FromAddress != 2 {
+		t.Fatalf("response %+v", d)
+	}
+	if n := inFlight(a.router(1)); n != 0 {
+		t.Fatalf("%d requests left in flight", n)
+	}
+}
+
+func TestConcurrentRequestsToOneNode(t *testing.T) {
+	a := newAirWith(t, func(r *Router) { r.Sequenced = true }, 1, 2)
+	h, got := collect()
+	a.router(2).Handle(1, h)
+
+	errs := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			errs <- a.router(1).SendWithAck(2, []byte{byte(i)})
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		receive(t, got)
+	}
+	if n := inFlight(a.router(1)); n != 0 {
+		t.Fatalf("%d requests left in flight", n)
+	}
+}
+
+func TestUnmatchedAckIgnored(t *testing.T) {
+	a := newAirWith(t, func(r *Router) { r.Sequenced = true }, 1, 2)
+	h, got := collect()
+	a.router(1).Handle(2, h)
+	a.cut(1, 2)
+	// node 2 is out of reach, a stale ack and a data frame arrive instead
+	a.onTransmit(1, func(d Data) {
+		if d.RequestAck {
+			a.inject(1, Data{FromAddress: 2, ToAddress: 1, SendAck: true, Data: []byte{d.Data[0] - 1}})
+			a.inject(1, Data{FromAddress: 2, ToAddress: 1, Data: []byte{0, 'x'}})
+		}
+	})
+	err := a.router(1).SendWithAck(2, []byte("x"))
+	if err == nil || err.Error() != "no ack response" {
+		t.Fatalf("got %v, want no ack response", err)
+	}
+	if d := receive(t, got); string(d.Data) != "x" {
+		t.Fatalf("handler received %+v", d)
+	}
+	if n := inFlight(a.router(1)); n != 0 {
+		t.Fatalf("%d requests left in flight", n)
+	}
+}
+
+func TestCloseTransmitsQueuedFrames(t *testing.T) {
+	for _, run := range []bool{true, false} {
+		f := newFakeRadio()
+		d, err := NewDevice(f, &RFMOptions{NodeID: 1, NetworkID: 100, PollInterval: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewRouter(d)
+		if run {
+			go r.Run()
+		}
+		for i := 0; i < 5; i++ {
+			err = r.Send(2, []byte{byte(i)})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.mu.Lock()
+		sent := f.sent
+		f.mu.Unlock()
+		if len(sent) != 5 {
+			t.Fatalf("running %v: %d of 5 frames sent before Close returned", run, len(sent))
+		}
+		for i, fifo := range sent {
+			if fifo[4] != byte(i) {
+				t.Fatalf("running %v: frame %d sent as % x", run, i, fifo)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package options holds the command line flags shared by the commands
+// serving an RFM69 module
+package options
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/host/v3"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+// Radio holds the flags selecting the pins and radio settings
+type Radio struct {
+	Node    byte
+	Network byte
+	Key     string
+	HCW     bool
+	Reset   string
+	IRQ     string
+}
+
+// Register adds the radio flags to fs
+func (o *Radio) Register(fs *flag.FlagSet) {
+	ByteVar(fs, &o.Node, "node", 1, "node ID")
+	ByteVar(fs, &o.Network, "network", 100, "network ID")
+	fs.StringVar(&o.Key, "key", "", "encryption key, 16 raw bytes, hex or base64")
+	fs.BoolVar(&o.HCW, "hcw", true, "module is an RFM69HCW")
+	fs.StringVar(&o.Reset, "reset", "GPIO22", "reset pin")
+	fs.StringVar(&o.IRQ, "irq", "GPIO25", "interrupt (DIO0) pin")
+}
+
+// Open initializes the host drivers and the radio
+func (o *Radio) Open() (*rfm69.Router, error) {
+	_, err := host.Init()
+	if err != nil {
+		return nil, err
+	}
+	reset := gpioreg.ByName(o.Reset)
+	if reset == nil {
+		return nil, fmt.Errorf("unknown pin %s", o.Reset)
+	}
+	irq := gpioreg.ByName(o.IRQ)
+	if irq == nil {
+		return nil, fmt.Errorf("unknown pin %s", o.IRQ)
+	}
+	return rfm69.Init(&rfm69.RFMOptions{
+		NodeID:        o.Node,
+		NetworkID:     o.Network,
+		IsRfm69HCW:    o.HCW,
+		EncryptionKey: rfm69.KeyString(o.Key),
+		ResetPin:      reset,
+		IrqPin:        irq,
+	})
+}
+
+// ByteVar defines a flag holding a value from 0 to 255, larger values are
+// rejected instead of wrapping around
+func ByteVar(fs *flag.FlagSet, p *byte, name string, value byte, usage string) {
+	*p = value
+	fs.Var((*byteValue)(p), name, usage)
+}
+
+type byteValue byte
+
+func (b *byteValue) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return fmt.Errorf("%q is not a number from 0 to 255", s)
+	}
+	*b = byteValue(v)
+	return nil
+}
+
+func (b *byteValue) String() string {
+	return strconv.Itoa(int(*b))
+}
@@ -0,0 +1,40 @@
+package options
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func parse(args ...string) (*Radio, error) {
+	var o Radio
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	o.Register(fs)
+	return &o, fs.Parse(args)
+}
+
+func TestDefaults(t *testing.T) {
+	o, err := parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Node != 1 || o.Network != 100 || !o.HCW || o.Reset != "GPIO22" || o.IRQ != "GPIO25" {
+		t.Fatalf("defaults %+v", o)
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	o, err := parse("-node", "255", "-network", "0x2a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Node != 255 || o.Network != 42 {
+		t.Fatalf("node %d network %d", o.Node, o.Network)
+	}
+	for _, v := range []string{"256", "300", "-1", "node"} {
+		if _, err := parse("-node", v); err == nil {
+			t.Errorf("node %s accepted", v)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package rfm69
+
+import (
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyLen is the length of the AES-128 key used by the radio
+const KeyLen = 16
+
+// ErrKeyLength is returned for keys that are not KeyLen bytes long
+var ErrKeyLength = errors.New("encryption key must be 16 bytes")
+
+// Key is an AES-128 key, it never prints its value
+type Key [KeyLen]byte
+
+func (k Key) String() string {
+	return "Key(REDACTED)"
+}
+
+// GoString keeps %#v from printing the key bytes
+func (k Key) GoString() string {
+	return k.String()
+}
+
+// KeyString is a key in any of the forms ParseKey accepts, it never prints
+// its value
+type KeyString string
+
+func (k KeyString) String() string {
+	if k == "" {
+		return ""
+	}
+	return "KeyString(REDACTED)"
+}
+
+// GoString keeps %#v from printing the key
+func (k KeyString) GoString() string {
+	return k.String()
+}
+
+// ParseKey parses a key given as 32 hex digits, base64 or 16 raw bytes
+func ParseKey(s string) (Key, error) {
+	var k Key
+	var raw []byte
+	var err error
+	switch len(s) {
+	case 2 * KeyLen:
+		raw, err = hex.DecodeString(s)
+	case 22:
+		raw, err = base64.RawStdEncoding.DecodeString(s)
+	case 24:
+		raw, err = base64.StdEncoding.DecodeString(s)
+	case KeyLen:
+		raw = []byte(s)
+	default:
+		return k, ErrKeyLength
+	}
+	if err != nil {
+		// the decoder errors quote the offending input, keep it out of logs
+		return k, errors.New("malformed encryption key")
+	}
+	if len(raw) != KeyLen {
+		return k, ErrKeyLength
+	}
+	copy(k[:], raw)
+	return k, nil
+}
+
+type scheduledKey struct {
+	key      Key
+	activeAt time.Time
+}
+
+// KeyStore holds the AES keys of one or more networks and when each becomes
+// active. After a rotation the previous key is still tried for Grace so
+// nodes can switch over one by one
+type KeyStore struct {
+	Grace time.Duration
+
+	mu   sync.Mutex
+	keys map[byte][]scheduledKey // by network ID, ordered by activeAt
+}
+
+// NewKeyStore creates an empty key store
+func NewKeyStore(grace time.Duration) *KeyStore {
+	return &KeyStore{
+		Grace: grace,
+		keys:  make(map[byte][]scheduledKey),
+	}
+}
+
+// Add schedules key to become active for networkID at activeAt
+func (ks *KeyStore) Add(networkID byte, key Key, activeAt time.Time) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	keys := append(ks.keys[networkID], scheduledKey{key: key, activeAt: activeAt})
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].activeAt.Before(keys[j].activeAt)
+	})
+	ks.keys[networkID] = keys
+}
+
+// Active returns the key of networkID in use at now
+func (ks *KeyStore) Active(networkID byte, now time.Time) (Key, bool) {
+	c := ks.Candidates(networkID, now)
+	if len(c) == 0 {
+		return Key{}, false
+	}
+	return c[0], true
+}
+
+// Candidates returns the active key of networkID followed by the previous
+// key while it is within the grace window
+func (ks *KeyStore) Candidates(networkID byte, now time.Time) []Key {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	keys := ks.keys[networkID]
+	i := sort.Search(len(keys), func(i int) bool {
+		return keys[i].activeAt.After(now)
+	}) - 1
+	if i < 0 {
+		return nil
+	}
+	c := []Key{keys[i].key}
+	if i > 0 && now.Sub(keys[i].activeAt) < ks.Grace {
+		c = append(c, keys[i-1].key)
+	}
+	return c
+}
+
+// loadKey writes key to the AES registers unless it is already loaded
+func (r *Device) loadKey(key Key) error {
+	r.aesMu.Lock()
+	defer r.aesMu.Unlock()
+	if r.aesOn && r.aesKey == key {
+		return nil
+	}
+	return r.encrypt(key[:])
+}
+
+// updateKeys follows the key schedule of Config.Keys, the active key stays
+// loaded. During a grace window CRC auto clear is turned off so frames
+// sent with the previous key reach the FIFO and can be retried with it
+func (r *Device) updateKeys() error {
+	if r.Config.Keys == nil {
+		return nil
+	}
+	cands := r.Config.Keys.Candidates(r.Config.NetworkID, time.Now())
+	if len(cands) == 0 {
+		return nil
+	}
+	err := r.setKeyHunting(len(cands) > 1)
+	if err != nil {
+		return err
+	}
+	return r.loadKey(cands[0])
+}
+
+// setKeyHunting records whether frames failing the CRC are tried with the
+// other keys of a grace window
+func (r *Device) setKeyHunting(hunting bool) error {
+	r.captureMu.Lock()
+	defer r.captureMu.Unlock()
+	if hunting == r.keyHunting {
+		return nil
+	}
+	r.keyHunting = hunting
+	err := r.updateCRCAutoClear()
+	if err != nil {
+		r.keyHunting = !hunting
+	}
+	return err
+}
+
+// huntingKey tells whether a grace window is open
+func (r *Device) huntingKey() bool {
+	r.captureMu.Lock()
+	defer r.captureMu.Unlock()
+	return r.keyHunting
+}
+
+// plausibility rates a decrypted frame header, a frame decrypted with the
+// wrong key has a random one. Addressed to this node or broadcast is 2, a
+// group frame 1 as the groups are only known to the Router
+func (r *Device) plausibility(d *Data) int {
+	switch {
+	case d.ToAddress == r.Config.NodeID || d.ToAddress == BroadcastAddress:
+		return 2
+	case d.Kind == KindGroup:
+		return 1
+	}
+	return 0
+}
+
+// otherKey decrypts a frame with the other keys of the grace window. The
+// radio decrypted the whole AES blocks following the length byte with the
+// loaded key, padding included, encrypting them again gives back the
+// ciphertext. It returns the most plausible frame of length bytes and its
+// plausibility
+func (r *Device) otherKey(key Key, blocks []byte, length int) (Data, int) {
+	if length < 3 || length > len(blocks) || len(blocks)%aes.BlockSize != 0 {
+		return Data{}, 0
+	}
+	loaded, err := aes.NewCipher(key[:])
+	if err != nil {
+		return Data{}, 0
+	}
+	cipher := make([]byte, len(blocks))
+	for i := 0; i < len(blocks); i += aes.BlockSize {
+		loaded.Encrypt(cipher[i:], blocks[i:])
+	}
+	var best Data
+	bestRank := 0
+	for _, k := range r.Config.Keys.Candidates(r.Config.NetworkID, time.Now()) {
+		if k == key {
+			continue
+		}
+		other, err := aes.NewCipher(k[:])
+		if err != nil {
+			continue
+		}
+		plain := make([]byte, len(blocks))
+		for i := 0; i < len(cipher); i += aes.BlockSize {
+			other.Decrypt(plain[i:], cipher[i:])
+		}
+		var d Data
+		parseFrame(plain[:length], &d)
+		if rank := r.plausibility(&d); rank > bestRank {
+			best, bestRank = d, rank
+		}
+	}
+	return best, bestRank
+}
+
+// txKey loads the active key before transmitting
+func (r *Device) txKey() error {
+	if r.Config.Keys == nil {
+		return nil
+	}
+	key, ok := r.Config.Keys.Active(r.Config.NetworkID, time.Now())
+	if !ok {
+		return nil
+	}
+	return r.loadKey(key)
+}
@@ -0,0 +1,130 @@
+package rfm69
+
+import (
+	"crypto/aes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = Key{0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6, 0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c}
+
+func TestKeyStringRedacted(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	o := RFMOptions{NodeID: 1, EncryptionKey: secret}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if s := fmt.Sprintf(format, o); strings.Contains(s, secret) {
+			t.Errorf("%s prints the key: %s", format, s)
+		}
+	}
+	k, err := ParseKey(string(o.EncryptionKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprintf("%v %#v", k, k); strings.Contains(s, "1") {
+		t.Errorf("Key prints its bytes: %s", s)
+	}
+}
+
+func TestKeyStoreCandidates(t *testing.T) {
+	now := time.Now()
+	ks := NewKeyStore(time.Hour)
+	old, cur := Key{1}, Key{2}
+	ks.Add(100, cur, now.Add(-time.Minute))
+	ks.Add(100, old, now.Add(-24*time.Hour))
+
+	if c := ks.Candidates(100, now); len(c) != 2 || c[0] != cur || c[1] != old {
+		t.Fatalf("candidates during grace %v", c)
+	}
+	if c := ks.Candidates(100, now.Add(2*time.Hour)); len(c) != 1 || c[0] != cur {
+		t.Fatalf("candidates after grace %v", c)
+	}
+	if _, ok := ks.Active(100, now.Add(-48*time.Hour)); ok {
+		t.Fatal("key active before its time")
+	}
+	if _, ok := ks.Active(7, now); ok {
+		t.Fatal("key for another network")
+	}
+}
+
+// ecb runs f over every AES block of in
+func ecb(key Key, in []byte, decrypt bool) []byte {
+	c, _ := aes.NewCipher(key[:])
+	out := make([]byte, len(in))
+	for i := 0; i < len(in); i += aes.BlockSize {
+		if decrypt {
+			c.Decrypt(out[i:], in[i:])
+		} else {
+			c.Encrypt(out[i:], in[i:])
+		}
+	}
+	return out
+}
+
+func TestGraceWindowReceivesBothKeys(t *testing.T) {
+	old, cur := Key{1, 1, 1}, Key{2, 2, 2}
+	ks := NewKeyStore(time.Hour)
+	ks.Add(100, old, time.Now().Add(-24*time.Hour))
+	ks.Add(100, cur, time.Now().Add(-time.Minute))
+	d, f := newTestDevice(t, &RFMOptions{NodeID: 1, NetworkID: 100, Keys: ks})
+
+	// the keys are loaded on the first idle check
+	deadline := time.Now().Add(time.Second)
+	for key, on := d.loadedKey(); !on || key != cur; key, on = d.loadedKey() {
+		if time.Now().After(deadline) {
+			t.Fatal("current key never loaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a node still on the old key, the radio decrypts with the current one
+	msg := make([]byte, aes.BlockSize)
+	copy(msg, []byte{1, 2, 0, 'o', 'l', 'd'})
+	fifo := ecb(cur, ecb(old, msg, false), true)
+	for _, crcOK := range []bool{true, false} {
+		f.receiveFifo(append([]byte{6}, fifo...), crcOK)
+		got := receive(t, d.Receive())
+		if got.FromAddress != 2 || string(got.Data) != "old" {
+			t.Fatalf("crc ok %v: received %+v", crcOK, got)
+		}
+	}
+
+	// a node already on the current key
+	msg = append([]byte{1, 3, 0}, []byte("current key!!")...)
+	f.receiveFifo(append([]byte{byte(len(msg))}, msg...), true)
+	got := receive(t, d.Receive())
+	if got.FromAddress != 3 || string(got.Data) != "current key!!" {
+		t.Fatalf("received %+v", got)
+	}
+}
+
+func TestEncryptWhileRunning(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			key := testKey[:]
+			if i%2 == 1 {
+				key = nil
+			}
+			err := d.Encrypt(key)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	// the loop checks every frame against the payload limit of AES
+	for i := 0; i < 20; i++ {
+		d.Send(&Data{ToAddress: 2, Data: []byte{byte(i)}})
+	}
+	f.transmitted(t, 20)
+	close(stop)
+	<-done
+}
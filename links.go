@@ -0,0 +1,188 @@
+package rfm69
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Link statistics windows
+const (
+	linkWindow     = 32 // acked sends and received frames remembered per node
+	minLinkSamples = 8  // before a link can be reported degraded
+)
+
+// LinkStats describes the quality of the link to a node. The error rate
+// and retries cover the last acked sends to the node, the RSSI the last
+// frames received from it
+type LinkStats struct {
+	Node       byte
+	Sends      int     // acked sends in the window
+	Failures   int     // sends that were never acked
+	PER        float64 // packet error rate, lost transmissions per attempt
+	AvgRetries float64
+	RssiMean   float64
+	RssiStddev float64
+	LastFei    int // Hz, of the last frame with a measured FEI
+	Degraded   bool
+	Updated    time.Time
+}
+
+// LinkHandler is called when a link degrades
+type LinkHandler func(LinkStats)
+
+// sendResult is the outcome of an acked send
+type sendResult struct {
+	attempts int
+	acked    bool
+}
+
+type link struct {
+	sends    []sendResult
+	rssi     []int
+	fei      int
+	degraded bool
+	updated  time.Time
+}
+
+// linkTable keeps link statistics per node
+type linkTable struct {
+	mu    sync.Mutex
+	links map[byte]*link
+}
+
+func (t *linkTable) get(node byte) *link {
+	if t.links == nil {
+		t.links = make(map[byte]*link)
+	}
+	l, ok := t.links[node]
+	if !ok {
+		l = &link{}
+		t.links[node] = l
+	}
+	return l
+}
+
+// sent records an acked send that took attempts transmissions
+func (t *linkTable) sent(node byte, attempts int, acked bool, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.get(node)
+	l.sends = append(l.sends, sendResult{attempts: attempts, acked: acked})
+	if len(l.sends) > linkWindow {
+		l.sends = l.sends[len(l.sends)-linkWindow:]
+	}
+	l.updated = now
+}
+
+// received records the signal of a frame from data.FromAddress
+func (t *linkTable) received(data *Data, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.get(data.FromAddress)
+	l.rssi = append(l.rssi, data.Rssi)
+	if len(l.rssi) > linkWindow {
+		l.rssi = l.rssi[len(l.rssi)-linkWindow:]
+	}
+	if data.FeiValid {
+		// the last measured one, frames caught too late carry none
+		l.fei = data.Fei
+	}
+	l.updated = now
+}
+
+// check updates the degraded state of node, it returns the stats and
+// whether the link just degraded
+func (t *linkTable) check(node byte, maxPER float64, minRssi int) (LinkStats, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.get(node)
+	s := l.stats(node)
+	attempts := 0
+	for _, r := range l.sends {
+		attempts += r.attempts
+	}
+	bad := maxPER > 0 && attempts >= minLinkSamples && s.PER > maxPER
+	bad = bad || minRssi != 0 && len(l.rssi) >= minLinkSamples && s.RssiMean < float64(minRssi)
+	degraded := bad && !l.degraded
+	l.degraded = bad
+	s.Degraded = bad
+	return s, degraded
+}
+
+func (l *link) stats(node byte) LinkStats {
+	s := LinkStats{Node: node, LastFei: l.fei, Degraded: l.degraded, Updated: l.updated}
+	attempts, delivered := 0, 0
+	for _, r := range l.sends {
+		attempts += r.attempts
+		if r.acked {
+			delivered++
+		} else {
+			s.Failures++
+		}
+	}
+	s.Sends = len(l.sends)
+	if attempts > 0 {
+		s.PER = float64(attempts-delivered) / float64(attempts)
+	}
+	if s.Sends > 0 {
+		s.AvgRetries = float64(attempts-s.Sends) / float64(s.Sends)
+	}
+	if n := len(l.rssi); n > 0 {
+		var sum float64
+		for _, v := range l.rssi {
+			sum += float64(v)
+		}
+		s.RssiMean = sum / float64(n)
+		var sq float64
+		for _, v := range l.rssi {
+			d := float64(v) - s.RssiMean
+			sq += d * d
+		}
+		s.RssiStddev = math.Sqrt(sq / float64(n))
+	}
+	return s
+}
+
+// Link returns the link statistics of a node
+func (r *Router) Link(node byte) (LinkStats, bool) {
+	r.links.mu.Lock()
+	defer r.links.mu.Unlock()
+	l, ok := r.links.links[node]
+	if !ok {
+		return LinkStats{}, false
+	}
+	return l.stats(node), true
+}
+
+// Links returns the link statistics of every node, ordered by ID
+func (r *Router) Links() []LinkStats {
+	r.links.mu.Lock()
+	defer r.links.mu.Unlock()
+	list := make([]LinkStats, 0, len(r.links.links))
+	for node, l := range r.links.links {
+		list = append(list, l.stats(node))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Node < list[j].Node })
+	return list
+}
+
+// linkSent records the outcome of an acked send and checks the link
+func (r *Router) linkSent(node byte, attempts int, acked bool) {
+	r.links.sent(node, attempts, acked, time.Now())
+	r.checkLink(node)
+}
+
+// linkReceived records the signal of a received frame and checks the link
+func (r *Router) linkReceived(data *Data) {
+	r.links.received(data, time.Now())
+	r.checkLink(data.FromAddress)
+}
+
+func (r *Router) checkLink(node byte) {
+	s, degraded := r.links.check(node, r.MaxPER, r.MinRssi)
+	if degraded && r.OnLinkDegraded != nil {
+		go r.OnLinkDegraded(s)
+	}
+}
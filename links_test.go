@@ -0,0 +1,55 @@
+package rfm69
+
+import (
+	"testing"
+	"time"
+)
+
+// linkOf waits until r has seen n frames from node and returns its stats
+func linkOf(t *testing.T, r *Router, node byte, n int) LinkStats {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r.links.mu.Lock()
+		l, ok := r.links.links[node]
+		if ok && len(l.rssi) >= n {
+			s := l.stats(node)
+			r.links.mu.Unlock()
+			return s
+		}
+		r.links.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatalf("fewer than %d frames from %d recorded", n, node)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLinkKeepsLastMeasuredFei(t *testing.T) {
+	e := NewEmulator(1)
+	r := NewRouter(e)
+	go r.Run()
+	defer r.Close()
+	e.Inject(Data{FromAddress: 2, ToAddress: 1, Rssi: -60, Fei: 500, FeiValid: true})
+	if s := linkOf(t, r, 2, 1); s.LastFei != 500 {
+		t.Fatalf("last fei %d, want 500", s.LastFei)
+	}
+	// caught after the packet was received, no measurement
+	e.Inject(Data{FromAddress: 2, ToAddress: 1, Rssi: -70})
+	s := linkOf(t, r, 2, 2)
+	if s.LastFei != 500 || s.RssiMean != -65 {
+		t.Fatalf("stats %+v, want fei 500 and mean rssi -65", s)
+	}
+}
+
+func TestLinkCountsFailedSends(t *testing.T) {
+	a := newAir(t, 1, 2)
+	a.cut(1, 2)
+	if err := a.router(1).SendWithAck(2, []byte("x")); err == nil {
+		t.Fatal("acked across a cut link")
+	}
+	s, ok := a.router(1).Link(2)
+	if !ok || s.Sends != 1 || s.Failures != 1 || s.PER != 1 {
+		t.Fatalf("stats %+v, want one failed send", s)
+	}
+}
@@ -1,7 +1,10 @@
 package rfm69
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"time"
 )
 
 // Send data
@@ -22,66 +25,234 @@ func (r *Device) loop() {
 	for {
 		select {
 		case dataToTransmit := <-r.tx:
-			// TODO: can send?
-			r.readWriteReg(REG_PACKETCONFIG2, 0xFB, RF_PACKET2_RXRESTART) // avoid RX deadlocks
-			err = r.SetModeAndWait(RF_OPMODE_STANDBY)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.writeReg(REG_DIOMAPPING1, RF_DIOMAPPING1_DIO0_00)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.writeFifo(dataToTransmit)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.SetMode(RF_OPMODE_TRANSMITTER)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			<-irq
-
-			err = r.SetModeAndWait(RF_OPMODE_STANDBY)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.writeReg(REG_DIOMAPPING1, RF_DIOMAPPING1_DIO0_01)
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = r.SetMode(RF_OPMODE_RECEIVER)
-			if err != nil {
-				log.Fatal(err)
-			}
+			r.transmit(irq, dataToTransmit)
 		case interrupt := <-irq:
-			if interrupt {
-				if r.mode != RF_OPMODE_RECEIVER {
-					continue
-				}
-				flags, err := r.readReg(REG_IRQFLAGS2)
-				if err != nil {
-					log.Fatal(err)
-				}
-				if flags&RF_IRQFLAGS2_PAYLOADREADY == 0 {
-					continue
-				}
-				data, err := r.readFifo()
+			if !interrupt {
+				// no edge within the wait period, poll the slow status flags
+				err = r.checkLowBattery()
 				if err != nil {
-					log.Fatal(err)
+					log.Println(err)
+					r.metrics.get().LoopError(err)
 				}
-				if r.OnReceive != nil {
-					go r.OnReceive(&data)
-				}
-				err = r.SetMode(RF_OPMODE_RECEIVER)
+				err = r.updateKeys()
 				if err != nil {
-					log.Fatal(err)
+					log.Println(err)
+					r.metrics.get().LoopError(err)
 				}
 			}
+			if r.inContinuous() || r.currentMode() != RF_OPMODE_RECEIVER {
+				continue
+			}
+			// also polled without an edge so timeouts and flags on unwired
+			// DIO pins are serviced
+			err = r.handleIRQ()
+			if err != nil {
+				log.Fatal(err)
+			}
 		case <-r.quit:
+			r.transmitQueued(irq)
+			close(r.rx)
 			r.quit <- true
 			return
 		}
 	}
 }
+
+// transmit sends a frame and waits until it is on air
+func (r *Device) transmit(irq chan bool, dataToTransmit *Data) {
+	if len(dataToTransmit.Data) > r.maxDataLen() {
+		err := fmt.Errorf("%d byte payload to %d too long", len(dataToTransmit.Data), dataToTransmit.ToAddress)
+		log.Println(err)
+		r.metrics.get().LoopError(err)
+		return
+	}
+	if r.Config.CSMA {
+		err := r.waitForChannel()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	r.readWriteReg(REG_PACKETCONFIG2, 0xFB, RF_PACKET2_RXRESTART) // avoid RX deadlocks
+	err := r.SetModeAndWait(RF_OPMODE_STANDBY)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = r.txKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rest, err := r.writeFifo(dataToTransmit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = r.SetModeAndWait(RF_OPMODE_TRANSMITTER)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = r.refill(rest)
+	if err == nil {
+		err = r.waitForPacketSent(irq)
+	}
+	if err != nil {
+		log.Println(err)
+		r.metrics.get().LoopError(err)
+	} else {
+		r.metrics.get().PacketSent(dataToTransmit.ToAddress)
+	}
+
+	err = r.SetModeAndWait(RF_OPMODE_STANDBY)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = r.SetModeAndWait(RF_OPMODE_RECEIVER)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// transmitQueued sends the frames queued so far, so none is lost on Close
+func (r *Device) transmitQueued(irq chan bool) {
+	for {
+		select {
+		case d := <-r.tx:
+			r.transmit(irq, d)
+		default:
+			return
+		}
+	}
+}
+
+// handleIRQ reads the interrupt flags and services every event pending
+func (r *Device) handleIRQ() error {
+	flags1, err := r.readReg(REG_IRQFLAGS1)
+	if err != nil {
+		return err
+	}
+	flags2, err := r.readReg(REG_IRQFLAGS2)
+	if err != nil {
+		return err
+	}
+	if flags2&RF_IRQFLAGS2_FIFOOVERRUN != 0 {
+		r.metrics.get().FIFOOverrun()
+		// cleared by writing it, which also clears the FIFO
+		err = r.writeReg(REG_IRQFLAGS2, RF_IRQFLAGS2_FIFOOVERRUN)
+		if err != nil {
+			return err
+		}
+	}
+	early := false
+	if flags1&RF_IRQFLAGS1_SYNCADDRESSMATCH != 0 && !r.syncSeen {
+		r.syncSeen = true
+		early = flags2&RF_IRQFLAGS2_PAYLOADREADY == 0
+		if early {
+			// measure the frequency error while the packet is on air,
+			// too late once it has been received
+			err = r.readWriteReg(REG_AFCFEI, 0xFF, RF_AFCFEI_FEI_START)
+			if err != nil {
+				return err
+			}
+			r.feiStarted = true
+		}
+		if r.OnSyncAddress != nil {
+			go r.OnSyncAddress()
+		}
+	}
+	if flags1&RF_IRQFLAGS1_TIMEOUT != 0 {
+		err = r.restartRx(flags1)
+		if err != nil {
+			return err
+		}
+		if r.OnTimeout != nil {
+			go r.OnTimeout()
+		}
+		return nil
+	}
+	ook := r.ookOptions()
+	if flags2&RF_IRQFLAGS2_PAYLOADREADY == 0 {
+		if _, aesOn := r.loadedKey(); !early || ook != nil || aesOn {
+			return nil
+		}
+		// caught while on air, read it as it arrives in case it is longer
+		// than the FIFO
+		data, raw, crcOK, err := r.readStream()
+		if err != nil {
+			return err
+		}
+		r.syncSeen = false
+		if raw == nil {
+			r.feiStarted = false
+			return r.readWriteReg(REG_PACKETCONFIG2, 0xFB, RF_PACKET2_RXRESTART)
+		}
+		return r.received(data, raw, crcOK)
+	}
+	r.syncSeen = false
+	if ook != nil {
+		return r.receiveOOK(ook)
+	}
+	data, raw, err := r.readFifo()
+	if err != nil {
+		return err
+	}
+	return r.received(data, raw, flags2&RF_IRQFLAGS2_CRCOK != 0)
+}
+
+// received completes a frame read from the FIFO and delivers it
+func (r *Device) received(data Data, raw []byte, crcOK bool) error {
+	var err error
+	data.Fei, data.FeiValid, err = r.measuredFEI()
+	if err != nil {
+		return err
+	}
+	if c := r.capture(); c != nil {
+		err = r.captureFrame(c, raw, &data, crcOK)
+		if err != nil {
+			log.Println(err)
+			r.metrics.get().LoopError(err)
+		}
+	}
+	key, aesOn := r.loadedKey()
+	if rank := r.plausibility(&data); r.huntingKey() && aesOn && (!crcOK || rank < 2) {
+		// maybe sent with the previous key of the grace window
+		pad, err := r.readPadding(len(raw) - 1)
+		if err != nil {
+			return err
+		}
+		d, other := r.otherKey(key, append(raw[1:], pad...), len(raw)-1)
+		if other > rank || !crcOK && other > 0 {
+			d.Rssi, d.Fei, d.FeiValid = data.Rssi, data.Fei, data.FeiValid
+			data, crcOK = d, true
+		}
+	}
+	if !crcOK {
+		// only seen with CRC auto clear off, the radio drops them otherwise
+		r.metrics.get().CRCFailure()
+		return nil
+	}
+	r.metrics.get().PacketReceived(data.FromAddress, data.Rssi)
+	select {
+	case r.rx <- data:
+	default:
+	}
+	if r.OnReceive != nil {
+		go r.OnReceive(&data)
+	}
+	return r.SetMode(RF_OPMODE_RECEIVER)
+}
+
+// waitForPacketSent consumes irq until the radio reports PacketSent, other
+// DIO pins may fire in the meantime
+func (r *Device) waitForPacketSent(irq <-chan bool) error {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		<-irq
+		flags, err := r.readReg(REG_IRQFLAGS2)
+		if err != nil {
+			return err
+		}
+		if flags&RF_IRQFLAGS2_PACKETSENT != 0 {
+			return nil
+		}
+	}
+	return errors.New("timeout waiting for packet sent")
+}
@@ -0,0 +1,324 @@
+package rfm69
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Mesh defaults
+const (
+	DefaultMaxHops          = 4
+	DefaultRouteTimeout     = 5 * time.Minute
+	DefaultDiscoveryTimeout = 2 * time.Second
+)
+
+// mesh message types
+const (
+	meshApp byte = iota
+	meshRouteRequest
+	meshRouteReply
+	meshRouteFailure
+)
+
+const meshHeaderLen = 5
+
+// ErrNoRoute is returned when route discovery found no path to a node
+var ErrNoRoute = errors.New("no route to node")
+
+// meshHeader precedes every mesh payload
+type meshHeader struct {
+	typ    byte
+	origin byte // node that created the message
+	dest   byte // final destination
+	id     byte
+	hops   byte // hops travelled so far
+}
+
+func (h meshHeader) marshal(body []byte) []byte {
+	return append([]byte{h.typ, h.origin, h.dest, h.id, h.hops}, body...)
+}
+
+func parseMeshHeader(b []byte) (meshHeader, []byte, bool) {
+	if len(b) < meshHeaderLen {
+		return meshHeader{}, nil, false
+	}
+	return meshHeader{typ: b[0], origin: b[1], dest: b[2], id: b[3], hops: b[4]}, b[meshHeaderLen:], true
+}
+
+// Route is an entry of the mesh route table
+type Route struct {
+	Dest    byte
+	NextHop byte
+	Hops    byte
+	Expires time.Time
+}
+
+// MeshHandle is called with messages delivered through the mesh
+type MeshHandle func(origin byte, payload []byte)
+
+// Mesh routes messages over several hops, in the style of RadioHead's
+// RHMesh: routes are found by flooding a route request, the destination
+// answers with a route reply along the reverse path, and messages are
+// forwarded hop by hop with acks
+type Mesh struct {
+	router *Router
+
+	MaxHops          byte
+	RouteTimeout     time.Duration
+	DiscoveryTimeout time.Duration
+
+	mu      sync.Mutex
+	routes  map[byte]*Route
+	seen    map[[2]byte]time.Time // route requests by origin and id
+	waiting map[byte][]chan struct{}
+	id      byte
+	handler MeshHandle
+}
+
+// NewMesh attaches a mesh layer to router
+func NewMesh(router *Router) *Mesh {
+	m := &Mesh{
+		router:           router,
+		MaxHops:          DefaultMaxHops,
+		RouteTimeout:     DefaultRouteTimeout,
+		DiscoveryTimeout: DefaultDiscoveryTimeout,
+		routes:           make(map[byte]*Route),
+		seen:             make(map[[2]byte]time.Time),
+		waiting:          make(map[byte][]chan struct{}),
+	}
+	if router.kindHandlers == nil {
+		router.kindHandlers = make(map[Kind]Handle)
+	}
+	router.kindHandlers[KindMesh] = func(d Data) {
+		// forwarding waits for acks the Run loop has to deliver
+		go m.receive(d)
+	}
+	return m
+}
+
+// Handle registers the handler for messages addressed to this node
+func (m *Mesh) Handle(handle MeshHandle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handler = handle
+}
+
+// Routes returns the current route table
+func (m *Mesh) Routes() []Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var routes []Route
+	for _, r := range m.routes {
+		if now.Before(r.Expires) {
+			routes = append(routes, *r)
+		}
+	}
+	return routes
+}
+
+// Send delivers payload to dest, discovering a route first if needed
+func (m *Mesh) Send(dest byte, payload []byte) error {
+	route, ok := m.route(dest)
+	if !ok {
+		err := m.discover(dest)
+		if err != nil {
+			return err
+		}
+		route, _ = m.route(dest)
+	}
+	h := meshHeader{typ: meshApp, origin: m.self(), dest: dest, id: m.nextID()}
+	err := m.sendHop(route.NextHop, h, payload)
+	if err != nil {
+		m.dropRoute(dest)
+	}
+	return err
+}
+
+func (m *Mesh) self() byte {
+	return m.router.Radio.Address()
+}
+
+func (m *Mesh) nextID() byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.id++
+	return m.id
+}
+
+// sendHop transmits to a neighbour and waits for its ack
+func (m *Mesh) sendHop(next byte, h meshHeader, body []byte) error {
+	_, err := m.router.request(context.Background(), nopSpan{}, next, KindMesh, h.marshal(body), true, 3, 40, false, 0)
+	return err
+}
+
+// discover floods a route request for dest and waits for the reply
+func (m *Mesh) discover(dest byte) error {
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.waiting[dest] = append(m.waiting[dest], done)
+	m.mu.Unlock()
+
+	h := meshHeader{typ: meshRouteRequest, origin: m.self(), dest: dest, id: m.nextID()}
+	m.remember(h)
+	_, err := m.router.request(context.Background(), nopSpan{}, BroadcastAddress, KindMesh, h.marshal([]byte{m.self()}), false, 0, 0, false, 0)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(m.DiscoveryTimeout):
+		return ErrNoRoute
+	}
+}
+
+func (m *Mesh) route(dest byte) (Route, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.routes[dest]
+	if !ok || time.Now().After(r.Expires) {
+		return Route{}, false
+	}
+	return *r, true
+}
+
+// learn records that dest is reachable through next in hops, keeping an
+// existing shorter route
+func (m *Mesh) learn(dest, next, hops byte) {
+	if dest == m.self() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if r, ok := m.routes[dest]; !ok || now.After(r.Expires) || hops <= r.Hops {
+		m.routes[dest] = &Route{Dest: dest, NextHop: next, Hops: hops, Expires: now.Add(m.RouteTimeout)}
+	}
+	for _, c := range m.waiting[dest] {
+		close(c)
+	}
+	delete(m.waiting, dest)
+}
+
+func (m *Mesh) dropRoute(dest byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.routes, dest)
+}
+
+// remember records a route request, it returns false if it was seen before
+func (m *Mesh) remember(h meshHeader) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, t := range m.seen {
+		if now.Sub(t) > m.DiscoveryTimeout*2 {
+			delete(m.seen, k)
+		}
+	}
+	key := [2]byte{h.origin, h.id}
+	if _, ok := m.seen[key]; ok {
+		return false
+	}
+	m.seen[key] = now
+	return true
+}
+
+// receive handles a mesh frame from a neighbour
+func (m *Mesh) receive(d Data) {
+	h, body, ok := parseMeshHeader(d.Data)
+	if !ok {
+		return
+	}
+	m.learn(d.FromAddress, d.FromAddress, 1)
+	switch h.typ {
+	case meshRouteRequest:
+		m.routeRequest(d.FromAddress, h, body)
+	case meshRouteReply:
+		m.learn(h.origin, d.FromAddress, h.hops+1)
+		if h.dest != m.self() {
+			m.forward(h, body)
+		}
+	case meshRouteFailure:
+		if h.dest == m.self() {
+			if len(body) > 0 {
+				m.dropRoute(body[0])
+			}
+			return
+		}
+		m.forward(h, body)
+	case meshApp:
+		if h.dest == m.self() {
+			m.mu.Lock()
+			handler := m.handler
+			m.mu.Unlock()
+			if handler != nil {
+				handler(h.origin, body)
+			}
+			return
+		}
+		m.forward(h, body)
+	}
+}
+
+// routeRequest answers a request for this node or floods it further, body
+// lists the nodes the request passed through
+func (m *Mesh) routeRequest(from byte, h meshHeader, visited []byte) {
+	if h.origin == m.self() || !m.remember(h) {
+		return
+	}
+	m.learn(h.origin, from, h.hops+1)
+	if h.dest == m.self() {
+		reply := meshHeader{typ: meshRouteReply, origin: m.self(), dest: h.origin, id: h.id}
+		err := m.sendHop(from, reply, nil)
+		if err != nil {
+			log.Printf("mesh: route reply to %d: %v", h.origin, err)
+		}
+		return
+	}
+	if h.hops+1 >= m.MaxHops {
+		return
+	}
+	for _, v := range visited {
+		if v == m.self() {
+			return
+		}
+	}
+	h.hops++
+	_, err := m.router.request(context.Background(), nopSpan{}, BroadcastAddress, KindMesh, h.marshal(append(visited, m.self())), false, 0, 0, false, 0)
+	if err != nil {
+		log.Printf("mesh: flooding route request: %v", err)
+	}
+}
+
+// forward passes a message one hop closer to h.dest, reporting a failure
+// back to the origin if the next hop is unreachable
+func (m *Mesh) forward(h meshHeader, body []byte) {
+	if h.hops+1 >= m.MaxHops {
+		return
+	}
+	route, ok := m.route(h.dest)
+	h.hops++
+	if ok {
+		if err := m.sendHop(route.NextHop, h, body); err == nil {
+			return
+		}
+		m.dropRoute(h.dest)
+	}
+	if h.typ == meshRouteFailure || h.typ == meshRouteReply {
+		return
+	}
+	back, ok := m.route(h.origin)
+	if !ok {
+		return
+	}
+	failure := meshHeader{typ: meshRouteFailure, origin: m.self(), dest: h.origin, id: m.nextID()}
+	err := m.sendHop(back.NextHop, failure, []byte{h.dest})
+	if err != nil {
+		log.Printf("mesh: route failure to %d: %v", h.origin, err)
+	}
+}
@@ -0,0 +1,68 @@
+package rfm69
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// meshAir runs a mesh on every node, 1 and 3 can only reach each other
+// through 2
+func meshAir(t *testing.T) (*air, map[byte]*Mesh) {
+	var mu sync.Mutex
+	meshes := make(map[byte]*Mesh)
+	a := newAirWith(t, func(r *Router) {
+		m := NewMesh(r)
+		m.DiscoveryTimeout = 500 * time.Millisecond
+		mu.Lock()
+		meshes[r.Radio.Address()] = m
+		mu.Unlock()
+	}, 1, 2, 3)
+	a.cut(1, 3)
+	return a, meshes
+}
+
+func TestMeshDiscoversMultiHopRoute(t *testing.T) {
+	_, meshes := meshAir(t)
+	got := make(chan string, 4)
+	meshes[3].Handle(func(origin byte, payload []byte) {
+		if origin == 1 {
+			got <- string(payload)
+		}
+	})
+
+	for _, msg := range []string{"first", "second"} {
+		err := meshes[1].Send(3, []byte(msg))
+		if err != nil {
+			t.Fatalf("send %q: %v", msg, err)
+		}
+		select {
+		case s := <-got:
+			if s != msg {
+				t.Fatalf("received %q, want %q", s, msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%q never arrived", msg)
+		}
+	}
+
+	var route *Route
+	for _, r := range meshes[1].Routes() {
+		if r.Dest == 3 {
+			r := r
+			route = &r
+		}
+	}
+	if route == nil || route.NextHop != 2 || route.Hops != 2 {
+		t.Fatalf("route to 3 %+v, want 2 hops through 2", route)
+	}
+}
+
+func TestMeshNoRoute(t *testing.T) {
+	_, meshes := meshAir(t)
+	err := meshes[1].Send(9, []byte("lost"))
+	if !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("send to a missing node: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package rfm69
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics receives counters and measurements from the Device and Router,
+// see package prometheus for an adapter. Methods are called from the
+// receive loop and must not block
+type Metrics interface {
+	PacketSent(node byte)
+	PacketReceived(node byte, rssi int)
+	// AckTimeout counts sends that never got acked, Retry every resend
+	AckTimeout(node byte)
+	Retry(node byte)
+	CRCFailure()
+	FIFOOverrun()
+	// ModeSwitch reports how long the radio took to become ready in mode
+	ModeSwitch(mode byte, latency time.Duration)
+	// CSMABackoff counts waits for a busy channel before sending, see
+	// RFMOptions.CSMA
+	CSMABackoff()
+	// LoopError counts errors the receive loop logged and carried on after
+	LoopError(err error)
+}
+
+// nopMetrics discards everything
+type nopMetrics struct{}
+
+func (nopMetrics) PacketSent(byte)                {}
+func (nopMetrics) PacketReceived(byte, int)       {}
+func (nopMetrics) AckTimeout(byte)                {}
+func (nopMetrics) Retry(byte)                     {}
+func (nopMetrics) CRCFailure()                    {}
+func (nopMetrics) FIFOOverrun()                   {}
+func (nopMetrics) ModeSwitch(byte, time.Duration) {}
+func (nopMetrics) CSMABackoff()                   {}
+func (nopMetrics) LoopError(error)                {}
+
+// metricsRef holds the Metrics of a Device or Router, it may be replaced
+// while the receive loop reports to it
+type metricsRef struct {
+	mu sync.Mutex
+	m  Metrics
+}
+
+func (r *metricsRef) set(m Metrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m = m
+}
+
+// get returns the current Metrics, nopMetrics if none is set
+func (r *metricsRef) get() Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m == nil {
+		return nopMetrics{}
+	}
+	return r.m
+}
+
+// SetMetrics reports the device's measurements to m
+func (r *Device) SetMetrics(m Metrics) {
+	r.metrics.set(m)
+}
+
+// SetMetrics reports the router's measurements to m, and those of the
+// radio if it supports metrics
+func (r *Router) SetMetrics(m Metrics) {
+	r.metrics.set(m)
+	if radio, ok := r.Radio.(interface{ SetMetrics(Metrics) }); ok {
+		radio.SetMetrics(m)
+	}
+}
+
+func (r *Router) stats() Metrics {
+	return r.metrics.get()
+}
@@ -0,0 +1,91 @@
+package rfm69
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// countMetrics counts the calls of every Metrics method
+type countMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+	modes  map[byte]int
+}
+
+func (m *countMetrics) inc(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[string]int)
+	}
+	m.counts[name]++
+}
+
+func (m *countMetrics) count(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[name]
+}
+
+func (m *countMetrics) mode(mode byte) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.modes[mode]
+}
+
+func (m *countMetrics) ModeSwitch(mode byte, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.modes == nil {
+		m.modes = make(map[byte]int)
+	}
+	m.modes[mode]++
+}
+
+func (m *countMetrics) PacketSent(byte)          { m.inc("sent") }
+func (m *countMetrics) PacketReceived(byte, int) { m.inc("received") }
+func (m *countMetrics) AckTimeout(byte)          { m.inc("ack_timeout") }
+func (m *countMetrics) Retry(byte)               { m.inc("retry") }
+func (m *countMetrics) CRCFailure()              { m.inc("crc") }
+func (m *countMetrics) FIFOOverrun()             { m.inc("overrun") }
+func (m *countMetrics) CSMABackoff()             { m.inc("backoff") }
+func (m *countMetrics) LoopError(error)          { m.inc("loop_error") }
+
+func TestMetricsRetriesAndAckTimeout(t *testing.T) {
+	a := newAir(t, 1, 2)
+	m := new(countMetrics)
+	a.router(1).SetMetrics(m)
+
+	err := a.router(1).SendWithAck(2, []byte("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.count("retry") != 0 || m.count("ack_timeout") != 0 {
+		t.Fatalf("acked send counted %v", m.counts)
+	}
+
+	err = a.router(1).SendWithAck(9, []byte("nobody"))
+	if err == nil {
+		t.Fatal("send to a missing node acked")
+	}
+	if m.count("retry") != 2 || m.count("ack_timeout") != 1 {
+		t.Fatalf("got %d retries and %d ack timeouts, want 2 and 1", m.count("retry"), m.count("ack_timeout"))
+	}
+}
+
+func TestSetMetricsWhileRunning(t *testing.T) {
+	a := newAir(t, 1, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			a.router(1).SetMetrics(new(countMetrics))
+			a.router(1).SetMetrics(nil)
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		a.router(1).SendWithAck(9, nil)
+	}
+	<-done
+}
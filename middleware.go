@@ -0,0 +1,51 @@
+package rfm69
+
+import "log"
+
+// Middleware wraps a Handle, e.g. to filter or log frames
+type Middleware func(Handle) Handle
+
+// HandleDefault registers the handler for frames from nodes without a
+// handler of their own
+func (r *Router) HandleDefault(handle Handle) {
+	r.defaultHandler = handle
+}
+
+// Use appends middleware applied to every handler, the first one added
+// runs first
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// wrap applies the middleware chain to h
+func (r *Router) wrap(h Handle) Handle {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return h
+}
+
+// Filter passes only frames for which keep returns true
+func Filter(keep func(Data) bool) Middleware {
+	return func(next Handle) Handle {
+		return func(d Data) {
+			if keep(d) {
+				next(d)
+			}
+		}
+	}
+}
+
+// Logger logs every frame before handing it on, nil uses the standard
+// logger
+func Logger(l *log.Logger) Middleware {
+	if l == nil {
+		l = log.Default()
+	}
+	return func(next Handle) Handle {
+		return func(d Data) {
+			l.Printf("%d -> %d kind %d rssi %d ack %v/%v: % x", d.FromAddress, d.ToAddress, d.Kind, d.Rssi, d.RequestAck, d.SendAck, d.Data)
+			next(d)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package rfm69
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	a := newAir(t, 1, 2)
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handle) Handle {
+			return func(d Data) {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				next(d)
+			}
+		}
+	}
+	h, got := collect()
+	a.router(2).Use(record("first"), record("second"))
+	a.router(2).Use(record("third"))
+	a.router(2).Handle(1, h)
+
+	err := a.router(1).Send(2, []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, got)
+	mu.Lock()
+	defer mu.Unlock()
+	if s := strings.Join(calls, " "); s != "first second third" {
+		t.Fatalf("middleware ran %s", s)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	a := newAir(t, 1, 2)
+	h, got := collect()
+	a.router(2).Use(Filter(func(d Data) bool { return d.Data[0] != 'd' }))
+	a.router(2).HandleDefault(h)
+
+	for _, payload := range []string{"drop", "keep"} {
+		err := a.router(1).Send(2, []byte(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := receive(t, got); string(d.Data) != "keep" {
+		t.Fatalf("received %q", d.Data)
+	}
+	nothing(t, got)
+}
+
+func TestLogger(t *testing.T) {
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	l := log.New(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}), "", 0)
+	a := newAir(t, 1, 2)
+	h, got := collect()
+	a.router(2).Use(Logger(l))
+	a.router(2).HandleDefault(h)
+
+	err := a.router(1).Send(2, []byte{0xAB, 0xCD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, got)
+	mu.Lock()
+	defer mu.Unlock()
+	if s := buf.String(); s != "1 -> 2 kind 0 rssi -127 ack false/false: ab cd\n" {
+		t.Fatalf("logged %q", s)
+	}
+}
+
+// writerFunc is an io.Writer calling itself
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestPromiscuousDeliversEverything(t *testing.T) {
+	a := newAirWith(t, func(r *Router) {
+		r.Promiscuous = r.Radio.Address() == 3
+	}, 1, 2, 3)
+	NewMesh(a.router(3))
+	h, got := collect()
+	a.router(3).HandleDefault(h)
+
+	err := a.router(1).SendWithAck(2, []byte("for 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, got); d.ToAddress != 2 || string(d.Data) != "for 2" {
+		t.Fatalf("sniffed %+v, want the frame for 2", d)
+	}
+	if d := receive(t, got); !d.SendAck || d.FromAddress != 2 || d.ToAddress != 1 {
+		t.Fatalf("sniffed %+v, want the ack of 2", d)
+	}
+
+	err = a.router(1).Hello("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, got); d.Kind != KindHello || string(d.Data) != "v1" {
+		t.Fatalf("sniffed %+v, want the hello", d)
+	}
+
+	a.inject(3, Data{FromAddress: 1, ToAddress: 2, Kind: KindMesh, Data: []byte{0}})
+	if d := receive(t, got); d.Kind != KindMesh {
+		t.Fatalf("sniffed %+v, want the mesh frame", d)
+	}
+	nothing(t, got)
+}
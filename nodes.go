@@ -0,0 +1,154 @@
+package rfm69
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Node table defaults
+const (
+	DefaultNodeTimeout = 5 * time.Minute
+	rssiHistory        = 16
+)
+
+// NodeInfo describes a node the Router has heard from
+type NodeInfo struct {
+	ID        byte
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Packets   uint64
+	Rssi      []int  // most recent last
+	Firmware  string // reported in the last hello
+	Online    bool
+}
+
+// NodeHandler is called when a node comes online or goes offline
+type NodeHandler func(NodeInfo)
+
+// nodeTable records every sender seen on the network
+type nodeTable struct {
+	mu    sync.Mutex
+	nodes map[byte]*NodeInfo
+}
+
+// seen records a frame from data.FromAddress, it returns true if the node
+// was offline or unknown before
+func (t *nodeTable) seen(data *Data, now time.Time) (NodeInfo, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.nodes == nil {
+		t.nodes = make(map[byte]*NodeInfo)
+	}
+	n, ok := t.nodes[data.FromAddress]
+	if !ok {
+		n = &NodeInfo{ID: data.FromAddress, FirstSeen: now}
+		t.nodes[data.FromAddress] = n
+	}
+	n.LastSeen = now
+	n.Packets++
+	n.Rssi = append(n.Rssi, data.Rssi)
+	if len(n.Rssi) > rssiHistory {
+		n.Rssi = n.Rssi[len(n.Rssi)-rssiHistory:]
+	}
+	came := !n.Online
+	n.Online = true
+	return n.copy(), came
+}
+
+// hello records the firmware version reported by node
+func (t *nodeTable) hello(node byte, firmware string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n, ok := t.nodes[node]; ok {
+		n.Firmware = firmware
+	}
+}
+
+// expire marks nodes not seen within timeout offline and returns them
+func (t *nodeTable) expire(now time.Time, timeout time.Duration) []NodeInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var gone []NodeInfo
+	for _, n := range t.nodes {
+		if n.Online && now.Sub(n.LastSeen) > timeout {
+			n.Online = false
+			gone = append(gone, n.copy())
+		}
+	}
+	return gone
+}
+
+func (t *nodeTable) get(node byte) (NodeInfo, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.nodes[node]
+	if !ok {
+		return NodeInfo{}, false
+	}
+	return n.copy(), true
+}
+
+func (t *nodeTable) list() []NodeInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]NodeInfo, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		list = append(list, n.copy())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (n *NodeInfo) copy() NodeInfo {
+	c := *n
+	c.Rssi = append([]int(nil), n.Rssi...)
+	return c
+}
+
+// Nodes returns every node heard from, ordered by ID
+func (r *Router) Nodes() []NodeInfo {
+	return r.nodes.list()
+}
+
+// Node returns what is known about a node
+func (r *Router) Node(id byte) (NodeInfo, bool) {
+	return r.nodes.get(id)
+}
+
+// Hello broadcasts the firmware version of this node
+func (r *Router) Hello(firmware string) error {
+	return r.HelloContext(context.Background(), firmware)
+}
+
+// HelloContext is Hello, giving up when ctx is done before the frame is
+// queued
+func (r *Router) HelloContext(ctx context.Context, firmware string) error {
+	frame, _, err := r.frame(BroadcastAddress, KindHello, false, []byte(firmware))
+	if err != nil {
+		return err
+	}
+	return r.queue(ctx, frame)
+}
+
+// trackNode updates the node table for a received frame
+func (r *Router) trackNode(data *Data) {
+	n, came := r.nodes.seen(data, time.Now())
+	if came && r.OnNodeOnline != nil {
+		go r.OnNodeOnline(n)
+	}
+}
+
+// expireNodes reports nodes that have gone quiet
+func (r *Router) expireNodes() {
+	timeout := r.NodeTimeout
+	if timeout <= 0 {
+		timeout = DefaultNodeTimeout
+	}
+	for _, n := range r.nodes.expire(time.Now(), timeout) {
+		if r.OnNodeOffline != nil {
+			go r.OnNodeOffline(n)
+		}
+	}
+}
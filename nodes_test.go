@@ -0,0 +1,96 @@
+package rfm69
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodeTracking(t *testing.T) {
+	online := make(chan NodeInfo, 1)
+	a := newAirWith(t, func(r *Router) {
+		r.OnNodeOnline = func(n NodeInfo) { online <- n }
+	}, 1, 2)
+	h, got := collect()
+	a.router(1).HandleDefault(h)
+
+	err := a.router(2).Hello("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-online:
+		if n.ID != 2 {
+			t.Fatalf("node %d came online, want 2", n.ID)
+		}
+	case <-got:
+		t.Fatal("hello delivered to the handler")
+	}
+	err = a.router(2).Send(1, []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, got)
+	n, ok := a.router(1).Node(2)
+	if !ok || !n.Online || n.Packets != 2 || n.Firmware != "v1" {
+		t.Fatalf("node 2: %+v", n)
+	}
+}
+
+func TestForgedFramesDontTrackNodes(t *testing.T) {
+	a := newAirWith(t, secured(t), 1, 2)
+	h, got := collect()
+	a.router(1).HandleDefault(h)
+
+	// unsealed frames neither bring a node online nor feed its link stats
+	a.inject(1, Data{FromAddress: 3, ToAddress: 1, Data: []byte("forged")})
+	nothing(t, got)
+	if n, ok := a.router(1).Node(3); ok {
+		t.Fatalf("forged sender tracked: %+v", n)
+	}
+	if s, ok := a.router(1).Link(3); ok {
+		t.Fatalf("forged sender has link stats: %+v", s)
+	}
+
+	err := a.router(2).Send(1, []byte("sealed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, got)
+	if _, ok := a.router(1).Node(2); !ok {
+		t.Fatal("authentic sender not tracked")
+	}
+}
+
+func TestNodeGoesOffline(t *testing.T) {
+	offline := make(chan NodeInfo, 2)
+	a := newAirWith(t, func(r *Router) {
+		r.NodeTimeout = time.Millisecond
+		r.OnNodeOffline = func(n NodeInfo) { offline <- n }
+	}, 1, 2)
+
+	err := a.router(2).Hello("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the node table is checked every second
+	select {
+	case n := <-offline:
+		if n.ID != 2 || n.Online {
+			t.Fatalf("offline %+v, want node 2", n)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("node 2 never went offline")
+	}
+	nodes := a.router(1).Nodes()
+	if len(nodes) != 1 || nodes[0].ID != 2 || nodes[0].Online {
+		t.Fatalf("nodes %+v, want node 2 offline", nodes)
+	}
+
+	// reported once
+	a.router(1).expireNodes()
+	select {
+	case n := <-offline:
+		t.Fatalf("offline again: %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
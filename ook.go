@@ -0,0 +1,314 @@
+package rfm69
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxOOKLen is the largest raw frame captured in OOK packet mode
+const MaxOOKLen = 64
+
+// maxOOKEdges bounds the edges kept while looking for a code on DATA
+const maxOOKEdges = 256
+
+var errNotOOK = errors.New("device not in OOK mode")
+
+// ookRegs are changed by SetOOK and restored by SetFSK
+var ookRegs = []byte{
+	REG_DATAMODUL, REG_BITRATEMSB, REG_BITRATELSB, REG_FRFMSB, REG_FRFMID, REG_FRFLSB,
+	REG_OOKPEAK, REG_OOKAVG, REG_OOKFIX, REG_RXBW, REG_SYNCCONFIG, REG_PACKETCONFIG1,
+	REG_PAYLOADLENGTH, REG_PACKETCONFIG2,
+}
+
+// OOKOptions configures on-off keying reception in packet mode without
+// sync word, every frame is a raw sample of the demodulated carrier
+type OOKOptions struct {
+	Frequency    uint32 // carrier in Hz, 0 keeps the current frequency
+	Bitrate      uint32 // samples per second
+	ThreshType   byte   // RF_OOKPEAK_THRESHTYPE_*
+	PeakStep     byte   // RF_OOKPEAK_PEAKTHRESHSTEP_*
+	PeakDec      byte   // RF_OOKPEAK_PEAKTHRESHDEC_*
+	AvgFilter    byte   // RF_OOKAVG_AVERAGETHRESHFILT_*
+	FixedThresh  byte   // fixed threshold or floor of the peak threshold in dB
+	RxBw         byte   // RegRxBw value
+	PacketLength byte   // raw bytes per frame, at most MaxOOKLen
+}
+
+// DefaultOOKOptions returns settings suitable for EV1527/PT2262 remotes
+func DefaultOOKOptions() *OOKOptions {
+	return &OOKOptions{
+		Frequency:    433920000,
+		Bitrate:      5000,
+		ThreshType:   RF_OOKPEAK_THRESHTYPE_PEAK,
+		PeakStep:     RF_OOKPEAK_PEAKTHRESHSTEP_000,
+		PeakDec:      RF_OOKPEAK_PEAKTHRESHDEC_000,
+		AvgFilter:    RF_OOKAVG_AVERAGETHRESHFILT_10,
+		FixedThresh:  RF_OOKFIX_FIXEDTHRESH_VALUE,
+		RxBw:         RF_RXBW_DCCFREQ_010 | RF_RXBW_MANT_24 | RF_RXBW_EXP_1,
+		PacketLength: MaxOOKLen,
+	}
+}
+
+// SetOOK switches the device to OOK reception, nil uses DefaultOOKOptions
+func (r *Device) SetOOK(options *OOKOptions) error {
+	if options == nil {
+		options = DefaultOOKOptions()
+	}
+	if options.PacketLength == 0 || options.PacketLength > MaxOOKLen {
+		return errors.New("invalid OOK packet length")
+	}
+	if r.ookOptions() == nil {
+		saved := make([]byte, len(ookRegs))
+		for i, reg := range ookRegs {
+			v, err := r.readReg(reg)
+			if err != nil {
+				return err
+			}
+			saved[i] = v
+		}
+		r.ookSaved = saved
+	}
+	err := r.SetModeAndWait(RF_OPMODE_STANDBY)
+	if err != nil {
+		return err
+	}
+	if options.Frequency != 0 {
+		err = r.SetFrequency(options.Frequency)
+		if err != nil {
+			return err
+		}
+	}
+	err = r.SetBitrate(options.Bitrate)
+	if err != nil {
+		return err
+	}
+	config := [][]byte{
+		{REG_DATAMODUL, RF_DATAMODUL_DATAMODE_PACKET | RF_DATAMODUL_MODULATIONTYPE_OOK | RF_DATAMODUL_MODULATIONSHAPING_00},
+		{REG_OOKPEAK, options.ThreshType | options.PeakStep | options.PeakDec},
+		{REG_OOKAVG, options.AvgFilter},
+		{REG_OOKFIX, options.FixedThresh},
+		{REG_RXBW, options.RxBw},
+		{REG_SYNCCONFIG, RF_SYNC_OFF},
+		{REG_PACKETCONFIG1, RF_PACKET1_FORMAT_FIXED | RF_PACKET1_DCFREE_OFF | RF_PACKET1_CRC_OFF | RF_PACKET1_ADRSFILTERING_OFF},
+		{REG_PAYLOADLENGTH, options.PacketLength},
+		{REG_PACKETCONFIG2, RF_PACKET2_RXRESTARTDELAY_NONE | RF_PACKET2_AUTORXRESTART_ON | RF_PACKET2_AES_OFF},
+	}
+	for _, c := range config {
+		err = r.writeReg(c[0], c[1])
+		if err != nil {
+			return err
+		}
+	}
+	r.ookMu.Lock()
+	r.ook = options
+	r.ookMu.Unlock()
+	return r.SetMode(RF_OPMODE_RECEIVER)
+}
+
+// SetFSK leaves OOK reception, restoring the frequency, bitrate and packet
+// settings in use before SetOOK
+func (r *Device) SetFSK() error {
+	if r.ookOptions() == nil {
+		return errNotOOK
+	}
+	err := r.SetModeAndWait(RF_OPMODE_STANDBY)
+	if err != nil {
+		return err
+	}
+	for i, reg := range ookRegs {
+		err = r.writeReg(reg, r.ookSaved[i])
+		if err != nil {
+			return err
+		}
+	}
+	r.ookMu.Lock()
+	r.ook = nil
+	r.ookMu.Unlock()
+	return r.SetMode(RF_OPMODE_RECEIVER)
+}
+
+// ookOptions returns the OOK settings, nil in FSK mode
+func (r *Device) ookOptions() *OOKOptions {
+	r.ookMu.Lock()
+	defer r.ookMu.Unlock()
+	return r.ook
+}
+
+// ReceiveOOK decodes the level changes of DATA in continuous mode with
+// OOKDecoders, see ReceiveEdges. Codes are passed to OnOOK and the channel
+// returned, which is closed by StopContinuous
+func (r *Device) ReceiveOOK() (<-chan OOKCode, error) {
+	edges, err := r.ReceiveEdges()
+	if err != nil {
+		return nil, err
+	}
+	codes := make(chan OOKCode, 16)
+	go func() {
+		defer close(codes)
+		decodeEdges(edges, r.OOKDecoders, func(code OOKCode) {
+			if r.OnOOK != nil {
+				go r.OnOOK(code)
+			}
+			select {
+			case codes <- code:
+			default: // reader too slow, drop
+			}
+		})
+	}()
+	return codes, nil
+}
+
+// decodeEdges runs decoders over the edges received so far whenever a new
+// one completes a pulse, the edges are dropped once a code is found
+func decodeEdges(edges <-chan Edge, decoders []OOKDecoder, found func(OOKCode)) {
+	var buf []Edge
+	for e := range edges {
+		buf = append(buf, e)
+		if len(buf) > maxOOKEdges {
+			buf = buf[len(buf)-maxOOKEdges:]
+		}
+		pulses := EdgesToPulses(buf)
+		for _, d := range decoders {
+			if code, ok := d.Decode(pulses); ok {
+				found(code)
+				// the last edge starts the next pulse
+				buf = append(buf[:0], e)
+				break
+			}
+		}
+	}
+}
+
+// receiveOOK reads a raw frame from the FIFO and runs the decoders on it
+func (r *Device) receiveOOK(ook *OOKOptions) error {
+	tx := make([]byte, int(ook.PacketLength)+1)
+	tx[0] = REG_FIFO & 0x7f
+	rx := make([]byte, len(tx))
+	err := r.spiDevice.Tx(tx, rx)
+	if err != nil {
+		return err
+	}
+	pulses := SamplesToPulses(rx[1:], time.Second/time.Duration(ook.Bitrate))
+	for _, d := range r.OOKDecoders {
+		if code, ok := d.Decode(pulses); ok {
+			if r.OnOOK != nil {
+				go r.OnOOK(code)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Pulse is a run of constant carrier level
+type Pulse struct {
+	High  bool
+	Width time.Duration
+}
+
+// SamplesToPulses converts a sampled bit stream (MSB first) into pulses
+func SamplesToPulses(samples []byte, period time.Duration) []Pulse {
+	var pulses []Pulse
+	for _, b := range samples {
+		for i := 7; i >= 0; i-- {
+			high := b&(1<<uint(i)) != 0
+			if n := len(pulses); n > 0 && pulses[n-1].High == high {
+				pulses[n-1].Width += period
+				continue
+			}
+			pulses = append(pulses, Pulse{High: high, Width: period})
+		}
+	}
+	return pulses
+}
+
+// OOKCode is a code decoded from a remote control or sensor
+type OOKCode struct {
+	Protocol string
+	Code     uint32        // symbols, first received in the MSB
+	Bits     int           // number of symbols in Code
+	Tristate string        // PT2262 address/data as 0, 1 and F
+	Unit     time.Duration // measured base pulse width
+}
+
+// OOKDecoder decodes a pulse train into a code
+type OOKDecoder interface {
+	Decode(pulses []Pulse) (OOKCode, bool)
+}
+
+// EV1527 decodes 24 bit EV1527 (HS1527, RT1527) learning code remotes
+type EV1527 struct{}
+
+// Decode implements OOKDecoder
+func (EV1527) Decode(pulses []Pulse) (OOKCode, bool) {
+	code, unit, ok := decodeSymbols(pulses, 24)
+	if !ok {
+		return OOKCode{}, false
+	}
+	return OOKCode{Protocol: "EV1527", Code: code, Bits: 24, Unit: unit}, true
+}
+
+// PT2262 decodes 12 trit PT2262 (SC2262) fixed code remotes
+type PT2262 struct{}
+
+// Decode implements OOKDecoder
+func (PT2262) Decode(pulses []Pulse) (OOKCode, bool) {
+	code, unit, ok := decodeSymbols(pulses, 24)
+	if !ok {
+		return OOKCode{}, false
+	}
+	var trits strings.Builder
+	for i := 22; i >= 0; i -= 2 {
+		switch (code >> uint(i)) & 3 {
+		case 0:
+			trits.WriteByte('0')
+		case 3:
+			trits.WriteByte('1')
+		case 1:
+			trits.WriteByte('F')
+		default:
+			return OOKCode{}, false
+		}
+	}
+	return OOKCode{Protocol: "PT2262", Code: code, Bits: 24, Tristate: trits.String(), Unit: unit}, true
+}
+
+// decodeSymbols finds a sync (1 unit high, 31 units low) and decodes the
+// n short/long symbols following or, if cut off, preceding it
+func decodeSymbols(pulses []Pulse, n int) (uint32, time.Duration, bool) {
+	for i := 0; i+1 < len(pulses); i++ {
+		high, low := pulses[i], pulses[i+1]
+		if !high.High || low.Width < 20*high.Width || low.Width > 45*high.Width {
+			continue
+		}
+		unit := (high.Width + low.Width) / 32
+		if code, ok := symbols(pulses, i+2, n, unit); ok {
+			return code, unit, true
+		}
+		if code, ok := symbols(pulses, i-2*n, n, unit); ok {
+			return code, unit, true
+		}
+	}
+	return 0, 0, false
+}
+
+// symbols decodes n high/low pairs starting at pulses[start], a short high
+// (1 unit) is 0 and a long high (3 units) is 1
+func symbols(pulses []Pulse, start, n int, unit time.Duration) (uint32, bool) {
+	if start < 0 || start+2*n > len(pulses) || !pulses[start].High {
+		return 0, false
+	}
+	var code uint32
+	for j := 0; j < n; j++ {
+		w := pulses[start+2*j].Width
+		code <<= 1
+		switch {
+		case w < unit/2 || w > 9*unit/2:
+			return 0, false
+		case w >= 2*unit:
+			code |= 1
+		}
+	}
+	return code, true
+}
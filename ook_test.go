@@ -0,0 +1,186 @@
+package rfm69
+
+import (
+	"testing"
+	"time"
+)
+
+// ev1527Edges returns the edges of code sent twice, each preceded by the
+// sync of 1 unit high and 31 units low
+func ev1527Edges(code uint32, unit time.Duration) []Edge {
+	var edges []Edge
+	t := time.Unix(0, 0)
+	pulse := func(high bool, units int) {
+		edges = append(edges, Edge{High: high, Time: t})
+		t = t.Add(time.Duration(units) * unit)
+	}
+	for repeat := 0; repeat < 2; repeat++ {
+		pulse(true, 1)
+		pulse(false, 31)
+		for i := 23; i >= 0; i-- {
+			if code&(1<<uint(i)) != 0 {
+				pulse(true, 3)
+				pulse(false, 1)
+			} else {
+				pulse(true, 1)
+				pulse(false, 3)
+			}
+		}
+	}
+	pulse(true, 1)
+	return edges
+}
+
+// symbolPulses returns the pulses of a sync followed by bits, a 1 is 3
+// units high and 1 low, a 0 is 1 unit high and 3 low
+func symbolPulses(bits string, unit time.Duration) []Pulse {
+	pulses := []Pulse{{High: true, Width: unit}, {Width: 31 * unit}}
+	for _, b := range bits {
+		high := 1
+		if b == '1' {
+			high = 3
+		}
+		pulses = append(pulses, Pulse{High: true, Width: time.Duration(high) * unit}, Pulse{Width: time.Duration(4-high) * unit})
+	}
+	return pulses
+}
+
+func TestPT2262(t *testing.T) {
+	const unit = 400 * time.Microsecond
+	malformed := symbolPulses("000111010011010100110100", unit)
+	malformed[10].Width = 6 * unit
+	// trits are sent as two symbols: 0 as 00, 1 as 11 and F as 01
+	for _, tc := range []struct {
+		name   string
+		pulses []Pulse
+		trits  string
+		ok     bool
+	}{
+		{"zeros", symbolPulses("000000000000000000000000", unit), "000000000000", true},
+		{"ones", symbolPulses("111111111111111111111111", unit), "111111111111", true},
+		{"floating", symbolPulses("010101010101010101010101", unit), "FFFFFFFFFFFF", true},
+		{"mixed", symbolPulses("000111010011010100110100", unit), "0F1F01FF01F0", true},
+		{"invalid trit", symbolPulses("100000000000000000000000", unit), "", false},
+		{"cut off", symbolPulses("0000000000", unit), "", false},
+		{"pulse too long", malformed, "", false},
+		{"no sync", symbolPulses("000111010011010100110100", unit)[2:], "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, ok := PT2262{}.Decode(tc.pulses)
+			if ok != tc.ok {
+				t.Fatalf("decoded %v, want %v", ok, tc.ok)
+			}
+			if ok && (code.Tristate != tc.trits || code.Protocol != "PT2262" || code.Bits != 24) {
+				t.Fatalf("decoded %+v, want trits %s", code, tc.trits)
+			}
+		})
+	}
+}
+
+func TestDecodeEdges(t *testing.T) {
+	edges := make(chan Edge, 256)
+	for _, e := range ev1527Edges(0xA5C3F0, 350*time.Microsecond) {
+		edges <- e
+	}
+	close(edges)
+	var codes []OOKCode
+	decodeEdges(edges, []OOKDecoder{EV1527{}}, func(c OOKCode) { codes = append(codes, c) })
+	if len(codes) == 0 {
+		t.Fatal("no code decoded")
+	}
+	for _, c := range codes {
+		if c.Protocol != "EV1527" || c.Code != 0xA5C3F0 || c.Bits != 24 {
+			t.Fatalf("decoded %+v", c)
+		}
+	}
+}
+
+func TestSetFSKRestoresPacketMode(t *testing.T) {
+	d, _ := newTestDevice(t, nil)
+	if err := d.SetFSK(); err != errNotOOK {
+		t.Fatalf("SetFSK in FSK mode: %v", err)
+	}
+	before := make([]byte, len(ookRegs))
+	for i, reg := range ookRegs {
+		before[i], _ = d.readReg(reg)
+	}
+	err := d.SetOOK(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := d.readReg(REG_DATAMODUL); v&RF_DATAMODUL_MODULATIONTYPE_OOK == 0 {
+		t.Fatalf("RegDataModul %#x after SetOOK", v)
+	}
+	err = d.SetOOK(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SetFSK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, reg := range ookRegs {
+		if v, _ := d.readReg(reg); v != before[i] {
+			t.Errorf("register %#x is %#x after SetFSK, want %#x", reg, v, before[i])
+		}
+	}
+}
+
+func TestSamplesToPulses(t *testing.T) {
+	const period = 200 * time.Microsecond
+	got := SamplesToPulses([]byte{0xF0, 0x0F, 0xFF}, period)
+	want := []Pulse{{High: true, Width: 4 * period}, {Width: 8 * period}, {High: true, Width: 12 * period}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	}
+	if p := SamplesToPulses(nil, period); len(p) != 0 {
+		t.Fatalf("pulses %+v from no samples", p)
+	}
+}
+
+// pulseSamples samples pulses every period, MSB first, padded with low
+// samples to n bytes
+func pulseSamples(pulses []Pulse, period time.Duration, n int) []byte {
+	samples := make([]byte, n)
+	bit := 0
+	for _, p := range pulses {
+		for i := 0; i < int(p.Width/period); i++ {
+			if p.High {
+				samples[bit/8] |= 0x80 >> uint(bit%8)
+			}
+			bit++
+		}
+	}
+	return samples
+}
+
+func TestReceiveOOKPacketMode(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	codes := make(chan OOKCode, 1)
+	d.OOKDecoders = []OOKDecoder{PT2262{}, EV1527{}}
+	d.OnOOK = func(c OOKCode) { codes <- c }
+	options := DefaultOOKOptions()
+	options.PacketLength = 40
+	err := d.SetOOK(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 samples per unit, the next sync starts after the code
+	period := time.Second / time.Duration(options.Bitrate)
+	pulses := append(symbolPulses("101100001111000000001010", 2*period), Pulse{High: true, Width: 2 * period})
+	f.receiveFifo(pulseSamples(pulses, period, int(options.PacketLength)), true)
+	select {
+	case c := <-codes:
+		if c.Protocol != "EV1527" || c.Code != 0xB0F00A || c.Unit != 2*period {
+			t.Fatalf("decoded %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no code decoded")
+	}
+}
@@ -0,0 +1,23 @@
+module github.com/charles-d-burton/rfm69-1/otel
+
+go 1.23.0
+
+require (
+	github.com/charles-d-burton/rfm69-1 v0.0.0-20261019033409-985e80b11629
+	go.opentelemetry.io/otel v1.37.0
+	go.opentelemetry.io/otel/sdk v1.37.0
+	go.opentelemetry.io/otel/trace v1.37.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.37.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	periph.io/x/conn/v3 v3.6.10 // indirect
+	periph.io/x/periph v3.6.8+incompatible // indirect
+)
+
+replace github.com/charles-d-burton/rfm69-1 => ../
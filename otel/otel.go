@@ -0,0 +1,68 @@
+// Package otel traces Router operations with OpenTelemetry:
+//
+//	r.Tracer = otel.New(otelapi.GetTracerProvider())
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+const instrumentation = "github.com/charles-d-burton/rfm69-1"
+
+// Tracer implements rfm69.Tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ rfm69.Tracer = (*Tracer)(nil)
+
+// New creates a tracer from provider
+func New(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(instrumentation)}
+}
+
+// Start implements rfm69.Tracer
+func (t *Tracer) Start(ctx context.Context, op string, node byte, size int) (context.Context, rfm69.Span) {
+	ctx, span := t.tracer.Start(ctx, "rfm69."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("rfm69.node", int(node)),
+			attribute.Int("rfm69.payload_size", size),
+		))
+	return ctx, &radioSpan{span: span}
+}
+
+// radioSpan adapts a trace.Span to rfm69.Span
+type radioSpan struct {
+	span trace.Span
+}
+
+func (s *radioSpan) Attempt(n int) {
+	s.span.AddEvent("attempt", trace.WithAttributes(attribute.Int("rfm69.attempt", n)))
+	s.span.SetAttributes(attribute.Int("rfm69.attempts", n))
+}
+
+func (s *radioSpan) Ack(rssi int) {
+	s.span.AddEvent("ack", trace.WithAttributes(attribute.Int("rfm69.rssi", rssi)))
+}
+
+func (s *radioSpan) Data(rssi, size int) {
+	s.span.AddEvent("data", trace.WithAttributes(
+		attribute.Int("rfm69.rssi", rssi),
+		attribute.Int("rfm69.response_size", size),
+	))
+}
+
+func (s *radioSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,73 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+func TestTracerSpans(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tr := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	// a get acked on the second attempt, then a send that failed
+	_, s := tr.Start(context.Background(), "get", 2, 4)
+	s.Attempt(1)
+	s.Attempt(2)
+	s.Ack(-40)
+	s.Data(-41, 8)
+	s.End(nil)
+	_, s = tr.Start(context.Background(), "send_with_ack", 3, 1)
+	s.Attempt(1)
+	s.End(errors.New("no ack response"))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(spans))
+	}
+	get := spans[0]
+	if get.Name() != "rfm69.get" || get.Status().Code == codes.Error {
+		t.Fatalf("span %s status %v", get.Name(), get.Status())
+	}
+	attrs := make(map[string]int64)
+	for _, a := range get.Attributes() {
+		attrs[string(a.Key)] = a.Value.AsInt64()
+	}
+	if attrs["rfm69.node"] != 2 || attrs["rfm69.payload_size"] != 4 || attrs["rfm69.attempts"] != 2 {
+		t.Fatalf("attributes %v", attrs)
+	}
+	var events []string
+	for _, e := range get.Events() {
+		events = append(events, e.Name)
+	}
+	if len(events) != 4 || events[2] != "ack" || events[3] != "data" {
+		t.Fatalf("events %v", events)
+	}
+
+	send := spans[1]
+	if send.Name() != "rfm69.send_with_ack" || send.Status().Code != codes.Error || send.Status().Description != "no ack response" {
+		t.Fatalf("span %s status %v", send.Name(), send.Status())
+	}
+}
+
+func TestRouterTraced(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	r := rfm69.NewRouter(rfm69.NewEmulator(1))
+	r.Tracer = New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	go r.Run()
+	defer r.Close()
+
+	err := r.Send(2, []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spans := rec.Ended(); len(spans) != 1 || spans[0].Name() != "rfm69.send" {
+		t.Fatalf("spans %v", spans)
+	}
+}
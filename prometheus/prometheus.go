@@ -0,0 +1,149 @@
+// Package prometheus exports rfm69.Metrics as Prometheus metrics:
+//
+//	r.SetMetrics(prometheus.New(prom.DefaultRegisterer))
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+const namespace = "rfm69"
+
+// Metrics implements rfm69.Metrics
+type Metrics struct {
+	sent, received, ackTimeouts, retries *prom.CounterVec
+	crcFailures, overruns, backoffs      prom.Counter
+	loopErrors                           prom.Counter
+	modeSwitch                           *prom.HistogramVec
+	rssi                                 *prom.HistogramVec
+	lastRssi                             *prom.GaugeVec
+}
+
+var _ rfm69.Metrics = (*Metrics)(nil)
+
+// New creates the metrics and registers them with reg, it panics if they
+// are registered already
+func New(reg prom.Registerer) *Metrics {
+	node := []string{"node"}
+	m := &Metrics{
+		sent: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "packets_sent_total",
+			Help: "Packets transmitted, by destination node.",
+		}, node),
+		received: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "packets_received_total",
+			Help: "Packets received, by sending node.",
+		}, node),
+		ackTimeouts: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "ack_timeouts_total",
+			Help: "Sends that were never acked, by destination node.",
+		}, node),
+		retries: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "retries_total",
+			Help: "Resends after a missing ack, by destination node.",
+		}, node),
+		crcFailures: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Name: "crc_failures_total",
+			Help: "Packets received with a bad CRC.",
+		}),
+		overruns: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Name: "fifo_overruns_total",
+			Help: "FIFO overruns.",
+		}),
+		backoffs: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Name: "csma_backoffs_total",
+			Help: "Waits for a busy channel before sending.",
+		}),
+		loopErrors: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Name: "loop_errors_total",
+			Help: "Errors the receive loop recovered from.",
+		}),
+		modeSwitch: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace, Name: "mode_switch_seconds",
+			Help:    "Time for the radio to become ready after a mode change.",
+			Buckets: prom.ExponentialBuckets(50e-6, 2, 10),
+		}, []string{"mode"}),
+		rssi: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace, Name: "rssi_dbm",
+			Help:    "RSSI of received packets, by sending node.",
+			Buckets: prom.LinearBuckets(-120, 10, 11),
+		}, node),
+		lastRssi: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: namespace, Name: "last_rssi_dbm",
+			Help: "RSSI of the last packet received, by sending node.",
+		}, node),
+	}
+	reg.MustRegister(m.sent, m.received, m.ackTimeouts, m.retries,
+		m.crcFailures, m.overruns, m.backoffs, m.loopErrors,
+		m.modeSwitch, m.rssi, m.lastRssi)
+	return m
+}
+
+func label(node byte) string {
+	return strconv.Itoa(int(node))
+}
+
+var modes = map[byte]string{
+	rfm69.RF_OPMODE_SLEEP:       "sleep",
+	rfm69.RF_OPMODE_STANDBY:     "standby",
+	rfm69.RF_OPMODE_SYNTHESIZER: "synthesizer",
+	rfm69.RF_OPMODE_TRANSMITTER: "transmit",
+	rfm69.RF_OPMODE_RECEIVER:    "receive",
+}
+
+// PacketSent implements rfm69.Metrics
+func (m *Metrics) PacketSent(node byte) {
+	m.sent.WithLabelValues(label(node)).Inc()
+}
+
+// PacketReceived implements rfm69.Metrics
+func (m *Metrics) PacketReceived(node byte, rssi int) {
+	l := label(node)
+	m.received.WithLabelValues(l).Inc()
+	m.rssi.WithLabelValues(l).Observe(float64(rssi))
+	m.lastRssi.WithLabelValues(l).Set(float64(rssi))
+}
+
+// AckTimeout implements rfm69.Metrics
+func (m *Metrics) AckTimeout(node byte) {
+	m.ackTimeouts.WithLabelValues(label(node)).Inc()
+}
+
+// Retry implements rfm69.Metrics
+func (m *Metrics) Retry(node byte) {
+	m.retries.WithLabelValues(label(node)).Inc()
+}
+
+// CRCFailure implements rfm69.Metrics
+func (m *Metrics) CRCFailure() {
+	m.crcFailures.Inc()
+}
+
+// FIFOOverrun implements rfm69.Metrics
+func (m *Metrics) FIFOOverrun() {
+	m.overruns.Inc()
+}
+
+// ModeSwitch implements rfm69.Metrics
+func (m *Metrics) ModeSwitch(mode byte, latency time.Duration) {
+	name, ok := modes[mode]
+	if !ok {
+		name = "unknown"
+	}
+	m.modeSwitch.WithLabelValues(name).Observe(latency.Seconds())
+}
+
+// CSMABackoff implements rfm69.Metrics
+func (m *Metrics) CSMABackoff() {
+	m.backoffs.Inc()
+}
+
+// LoopError implements rfm69.Metrics
+func (m *Metrics) LoopError(err error) {
+	m.loopErrors.Inc()
+}
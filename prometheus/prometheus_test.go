@@ -0,0 +1,67 @@
+package prometheus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	m := New(reg)
+	m.PacketSent(2)
+	m.PacketSent(2)
+	m.PacketReceived(3, -60)
+	m.PacketReceived(3, -40)
+	m.CRCFailure()
+	m.ModeSwitch(rfm69.RF_OPMODE_RECEIVER, time.Millisecond)
+	m.ModeSwitch(0xFF, time.Millisecond)
+	m.LoopError(errors.New("spi"))
+
+	for _, tc := range []struct {
+		name string
+		c    prom.Collector
+		want float64
+	}{
+		{"sent to 2", m.sent.WithLabelValues("2"), 2},
+		{"received from 3", m.received.WithLabelValues("3"), 2},
+		{"last rssi of 3", m.lastRssi.WithLabelValues("3"), -40},
+		{"crc failures", m.crcFailures, 1},
+		{"loop errors", m.loopErrors, 1},
+	} {
+		if got := testutil.ToFloat64(tc.c); got != tc.want {
+			t.Errorf("%s %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if n := testutil.CollectAndCount(m.modeSwitch); n != 2 {
+		t.Errorf("%d mode switch series, want receive and unknown", n)
+	}
+	if _, err := reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouterMetrics(t *testing.T) {
+	m := New(prom.NewRegistry())
+	r := rfm69.NewRouter(rfm69.NewEmulator(1))
+	r.SetMetrics(m)
+	go r.Run()
+	defer r.Close()
+
+	// nobody acks
+	err := r.SendWithAck(2, []byte("x"))
+	if err == nil {
+		t.Fatal("send to a missing node acked")
+	}
+	if got := testutil.ToFloat64(m.ackTimeouts.WithLabelValues("2")); got != 1 {
+		t.Errorf("ack timeouts %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.retries.WithLabelValues("2")); got != 2 {
+		t.Errorf("retries %v, want 2", got)
+	}
+}
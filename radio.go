@@ -0,0 +1,104 @@
+package rfm69
+
+import "errors"
+
+// ErrUnsupported is returned for operations the radio cannot perform
+var ErrUnsupported = errors.New("not supported by the radio")
+
+// Radio is a transceiver the Router sends and receives frames with.
+// Device implements it for the RFM69
+type Radio interface {
+	// Transmit queues a frame for sending
+	Transmit(d *Data) error
+	// Receive returns the channel received frames are delivered on, it is
+	// closed when the radio is closed
+	Receive() <-chan Data
+	SetMode(mode byte) error
+	// RSSI returns the current signal strength in dBm
+	RSSI() (int, error)
+	// Address is the node ID frames are sent from
+	Address() byte
+	Close() error
+}
+
+// Configurer is implemented by radios whose settings can be read and
+// changed at runtime
+type Configurer interface {
+	RadioConfig() (RadioConfig, error)
+	SetRadioConfig(RadioConfig) error
+	Registers() ([]Register, error)
+}
+
+var (
+	_ Radio      = (*Device)(nil)
+	_ Configurer = (*Device)(nil)
+)
+
+// Transmit implements Radio
+func (r *Device) Transmit(d *Data) error {
+	if len(d.Data) > MaxLongDataLen {
+		return ErrPayloadSize
+	}
+	r.Send(d)
+	return nil
+}
+
+// Receive implements Radio, frames are dropped while the channel is full.
+// OnReceive is called for every frame as well
+func (r *Device) Receive() <-chan Data {
+	return r.rx
+}
+
+// RSSI implements Radio
+func (r *Device) RSSI() (int, error) {
+	return r.readRSSI(false)
+}
+
+// Address implements Radio
+func (r *Device) Address() byte {
+	return r.Config.NodeID
+}
+
+// RadioConfig implements Configurer
+func (r *Device) RadioConfig() (RadioConfig, error) {
+	freq, err := r.Frequency()
+	if err != nil {
+		return RadioConfig{}, err
+	}
+	br, err := r.Bitrate()
+	if err != nil {
+		return RadioConfig{}, err
+	}
+	return RadioConfig{
+		NodeID:     r.Config.NodeID,
+		NetworkID:  r.Config.NetworkID,
+		Frequency:  freq,
+		Bitrate:    br,
+		PowerLevel: r.PowerLevel(),
+	}, nil
+}
+
+// SetRadioConfig implements Configurer
+func (r *Device) SetRadioConfig(c RadioConfig) error {
+	err := r.SetAddress(c.NodeID)
+	if err != nil {
+		return err
+	}
+	err = r.SetNetwork(c.NetworkID)
+	if err != nil {
+		return err
+	}
+	if c.Frequency != 0 {
+		err = r.SetFrequency(c.Frequency)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Bitrate != 0 {
+		err = r.SetBitrate(c.Bitrate)
+		if err != nil {
+			return err
+		}
+	}
+	return r.SetPowerLevel(c.PowerLevel)
+}
@@ -121,6 +121,7 @@ const (
 	REG_TESTPA1       = 0x5A // only present on RFM69HW/SX1231H
 	REG_TESTPA2       = 0x5C // only present on RFM69HW/SX1231H
 	REG_TESTDAGC      = 0x6F
+	REG_TESTAFC       = 0x71
 
 	//******************************************************
 	// RF69/SX1231 bit control definition
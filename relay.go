@@ -0,0 +1,195 @@
+package rfm69
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// Relay defaults
+const (
+	DefaultRelayTTL    = 3
+	DefaultRelayWindow = 2 * time.Second
+)
+
+const relayHeaderLen = 4
+
+// relayHeader precedes the payload of a KindRelay frame
+type relayHeader struct {
+	dest   byte // final destination
+	origin byte // original sender
+	ttl    byte // hops left
+	ctl    byte // control byte of the original frame
+}
+
+func parseRelayHeader(b []byte) (relayHeader, []byte, bool) {
+	if len(b) < relayHeaderLen {
+		return relayHeader{}, nil, false
+	}
+	return relayHeader{dest: b[0], origin: b[1], ttl: b[2], ctl: b[3]}, b[relayHeaderLen:], true
+}
+
+func (h relayHeader) marshal(body []byte) []byte {
+	return append([]byte{h.dest, h.origin, h.ttl, h.ctl}, body...)
+}
+
+func ctlByte(d *Data) byte {
+	ctl := byte(d.Kind) & kindMask
+	if d.RequestAck {
+		ctl |= 0x40
+	}
+	if d.SendAck {
+		ctl |= 0x80
+	}
+	return ctl
+}
+
+// Relay turns a Router into a range extender. Frames overheard for a
+// destination in Routes are re-transmitted to its next hop wrapped with the
+// original sender and a hop budget, Go nodes unwrap them transparently.
+// Acks travel back the same way if the origin is in Routes as well. Enable
+// Router.Sequenced on all nodes so frames heard both directly and through
+// the relay are delivered once
+type Relay struct {
+	router *Router
+
+	// Routes maps destination node IDs to the next hop towards them, which
+	// may be the destination itself
+	Routes map[byte]byte
+	TTL    byte
+	Window time.Duration
+
+	mu   sync.Mutex
+	seen map[relayKey]relayed
+}
+
+// relayKey identifies a relayed frame, by its sequence number if it has a
+// readable one and by its contents otherwise
+type relayKey struct {
+	dest, origin, ctl byte
+	seq               int // -1 if the frame has no readable sequence number
+	sum               uint64
+}
+
+// relayed records when a frame was last relayed and with how many hops left
+type relayed struct {
+	at  time.Time
+	ttl byte
+}
+
+// NewRelay enables relaying on router
+func NewRelay(router *Router, routes map[byte]byte) *Relay {
+	rl := &Relay{
+		router: router,
+		Routes: routes,
+		TTL:    DefaultRelayTTL,
+		Window: DefaultRelayWindow,
+		seen:   make(map[relayKey]relayed),
+	}
+	router.relay = rl
+	return rl
+}
+
+// overheard relays a plain frame addressed to a node in Routes, it returns
+// true if the frame was not for this node
+func (rl *Relay) overheard(d Data) bool {
+	self := rl.router.Radio.Address()
+	if d.ToAddress == self || d.FromAddress == self || d.ToAddress == BroadcastAddress || d.Kind == KindGroup {
+		// group IDs are no node IDs to route to
+		return false
+	}
+	h := relayHeader{dest: d.ToAddress, origin: d.FromAddress, ttl: rl.TTL, ctl: ctlByte(&d)}
+	rl.send(h, d.Data)
+	return !rl.router.Promiscuous
+}
+
+// send transmits a relayed frame to the next hop unless it was relayed
+// recently, has run out of hops or has no route
+func (rl *Relay) send(h relayHeader, body []byte) {
+	next, ok := rl.Routes[h.dest]
+	if !ok || h.ttl == 0 || next == h.origin {
+		return
+	}
+	if rl.duplicate(h, body) {
+		return
+	}
+	payload := h.marshal(body)
+	if len(payload) > MaxLongDataLen {
+		log.Printf("relay: frame %d -> %d too long to relay", h.origin, h.dest)
+		return
+	}
+	// transmit right away, this runs on the Run goroutine that drains tx
+	err := rl.router.Radio.Transmit(&Data{ToAddress: next, Kind: KindRelay, Data: payload})
+	if err != nil {
+		log.Println(err)
+		rl.router.stats().LoopError(err)
+	}
+}
+
+// key identifies the frame h wraps. Sequenced frames are told apart by
+// their sequence number, unless a Framer hides it
+func (rl *Relay) key(h relayHeader, body []byte) relayKey {
+	k := relayKey{dest: h.dest, origin: h.origin, ctl: h.ctl, seq: -1}
+	kind := Kind(h.ctl & kindMask)
+	if rl.router.Sequenced && rl.router.Framer == nil && sequencedKind(kind) && len(body) > 0 {
+		k.seq = int(body[0])
+		return k
+	}
+	sum := fnv.New64a()
+	sum.Write(body)
+	k.sum = sum.Sum64()
+	return k
+}
+
+// duplicate reports whether h is an echo of a frame relayed within Window,
+// one that comes back with fewer hops left after going round a loop or
+// through another relay. Retries of the origin arrive with as many hops as
+// the first copy and are relayed again, in case that one got lost
+func (rl *Relay) duplicate(h relayHeader, body []byte) bool {
+	key := rl.key(h, body)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	for k, s := range rl.seen {
+		if now.Sub(s.at) > rl.Window {
+			delete(rl.seen, k)
+		}
+	}
+	if s, ok := rl.seen[key]; ok && h.ttl < s.ttl {
+		return true
+	}
+	rl.seen[key] = relayed{at: now, ttl: h.ttl}
+	return false
+}
+
+// relayed handles a KindRelay frame: it is unwrapped and dispatched if this
+// node is the destination, otherwise passed on by the relay if there is one
+func (r *Router) relayed(d Data) {
+	if d.ToAddress != r.Radio.Address() {
+		return
+	}
+	h, body, ok := parseRelayHeader(d.Data)
+	if !ok {
+		return
+	}
+	if h.dest == r.Radio.Address() {
+		r.dispatch(Data{
+			ToAddress:   h.dest,
+			FromAddress: h.origin,
+			Data:        body,
+			RequestAck:  h.ctl&0x40 != 0,
+			SendAck:     h.ctl&0x80 != 0,
+			Kind:        Kind(h.ctl & kindMask),
+			Rssi:        d.Rssi,
+			Fei:         d.Fei,
+			FeiValid:    d.FeiValid,
+		})
+		return
+	}
+	if r.relay != nil {
+		h.ttl--
+		r.relay.send(h, body)
+	}
+}
@@ -0,0 +1,128 @@
+package rfm69
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// relayedAir sets up node 2 relaying between 1 and 3, which can't hear each
+// other, and counts the frames it relays
+func relayedAir(t *testing.T) (*air, func() int) {
+	var mu sync.Mutex
+	relays := 0
+	a := newAirWith(t, func(r *Router) {
+		r.Sequenced = true
+		if r.Radio.Address() == 2 {
+			NewRelay(r, map[byte]byte{1: 1, 3: 3})
+		}
+	}, 1, 2, 3)
+	a.cut(1, 3)
+	a.onTransmit(2, func(d Data) {
+		if d.Kind == KindRelay {
+			mu.Lock()
+			relays++
+			mu.Unlock()
+		}
+	})
+	return a, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return relays
+	}
+}
+
+func TestRelayDeliversAndAcks(t *testing.T) {
+	a, _ := relayedAir(t)
+	h, got := collect()
+	a.router(3).Handle(1, h)
+
+	for i := 0; i < 10; i++ {
+		err := a.router(1).SendWithAck(3, []byte{byte(i)})
+		if err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		if d := receive(t, got); d.FromAddress != 1 || d.Data[0] != byte(i) {
+			t.Fatalf("send %d received %+v", i, d)
+		}
+	}
+	nothing(t, got)
+}
+
+func TestRelaySkipsGroups(t *testing.T) {
+	a, relays := relayedAir(t)
+	h, got := collect()
+	a.router(3).Subscribe(3)
+	a.router(3).Handle(1, h)
+
+	// group 3 is not node 3
+	err := a.router(1).Multicast(3, []byte("group"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nothing(t, got)
+	time.Sleep(10 * time.Millisecond)
+	if n := relays(); n != 0 {
+		t.Fatalf("%d multicast frames relayed", n)
+	}
+}
+
+func TestRelayForwardsRetries(t *testing.T) {
+	a, relays := relayedAir(t)
+	h, got := collect()
+	a.router(3).Handle(1, h)
+	var mu sync.Mutex
+	dropped := false
+	a.lose(func(from byte, d Data) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if from == 2 && d.Kind == KindRelay && !dropped {
+			dropped = true
+			return true
+		}
+		return false
+	})
+
+	err := a.router(1).SendWithAck(3, []byte("retry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, got); string(d.Data) != "retry" {
+		t.Fatalf("received %+v", d)
+	}
+	nothing(t, got)
+	if n := relays(); n < 3 {
+		t.Fatalf("%d frames relayed, want the lost one, the retry and its ack", n)
+	}
+}
+
+func TestRelayForwardsRepeatedPayloads(t *testing.T) {
+	a, _ := relayedAir(t)
+	h, got := collect()
+	a.router(3).Handle(1, h)
+
+	for i := 0; i < 3; i++ {
+		err := a.router(1).SendWithAck(3, []byte("same"))
+		if err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		receive(t, got)
+	}
+	nothing(t, got)
+}
+
+func TestRelayDropsEchoes(t *testing.T) {
+	a := newAir(t, 2)
+	rl := NewRelay(a.router(2), map[byte]byte{3: 3})
+	h := relayHeader{dest: 3, origin: 1, ttl: DefaultRelayTTL, ctl: byte(KindData)}
+	if rl.duplicate(h, []byte("x")) {
+		t.Fatal("first copy is a duplicate")
+	}
+	if rl.duplicate(h, []byte("x")) {
+		t.Fatal("retry with as many hops left is a duplicate")
+	}
+	h.ttl--
+	if !rl.duplicate(h, []byte("x")) {
+		t.Fatal("echo with fewer hops left relayed again")
+	}
+}
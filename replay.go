@@ -0,0 +1,84 @@
+package rfm69
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ReplayTarget receives the frames of a replayed capture
+type ReplayTarget interface {
+	Replay(f *CapturedFrame) error
+}
+
+// ReplayFunc adapts a function to ReplayTarget
+type ReplayFunc func(f *CapturedFrame) error
+
+// Replay implements ReplayTarget
+func (fn ReplayFunc) Replay(f *CapturedFrame) error {
+	return fn(f)
+}
+
+// Replay implements ReplayTarget by injecting the frame into RX with its
+// recorded RSSI
+func (e *Emulator) Replay(f *CapturedFrame) error {
+	d, err := f.Data()
+	if err != nil {
+		return err
+	}
+	return e.Inject(d)
+}
+
+// Retransmit returns a target sending replayed frames on radio under
+// their original sender address
+func Retransmit(radio Radio) ReplayTarget {
+	return ReplayFunc(func(f *CapturedFrame) error {
+		d, err := f.Data()
+		if err != nil {
+			return err
+		}
+		d.Rssi = 0
+		d.Replayed = true
+		return radio.Transmit(&d)
+	})
+}
+
+// Replay feeds the frames of a capture to target, spaced as recorded
+// divided by speed, or as fast as possible if speed is zero. Frames that
+// failed the CRC are skipped as the radio would have dropped them. It
+// returns the number of frames replayed
+func Replay(ctx context.Context, r *PcapReader, target ReplayTarget, speed float64) (int, error) {
+	var n int
+	var first time.Time
+	start := time.Now()
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if !f.CRCOK {
+			continue
+		}
+		if speed > 0 {
+			if first.IsZero() {
+				first = f.Time
+			}
+			at := start.Add(time.Duration(float64(f.Time.Sub(first)) / speed))
+			select {
+			case <-time.After(time.Until(at)):
+			case <-ctx.Done():
+				return n, ctx.Err()
+			}
+		} else if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		err = target.Replay(f)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
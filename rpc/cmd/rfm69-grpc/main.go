@@ -0,0 +1,36 @@
+// Command rfm69-grpc serves an RFM69 module over gRPC, see package rpc
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"google.golang.org/grpc"
+
+	"github.com/charles-d-burton/rfm69-1/internal/options"
+	"github.com/charles-d-burton/rfm69-1/rpc"
+)
+
+func main() {
+	var radio options.Radio
+	radio.Register(flag.CommandLine)
+	listen := flag.String("listen", ":6969", "gRPC listen address")
+	flag.Parse()
+
+	r, err := radio.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	go r.Run()
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g := grpc.NewServer()
+	rpc.NewServer(r).Register(g)
+	err = g.Serve(lis)
+	r.Close()
+	log.Fatal(err)
+}
@@ -0,0 +1,21 @@
+module github.com/charles-d-burton/rfm69-1/rpc
+
+go 1.23.0
+
+require (
+	github.com/charles-d-burton/rfm69-1 v0.0.0-20261019033025-f300a7b2a05c
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7
+	google.golang.org/grpc v1.75.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	golang.org/x/net v0.41.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
+	periph.io/x/conn/v3 v3.6.10 // indirect
+	periph.io/x/host/v3 v3.7.2 // indirect
+	periph.io/x/periph v3.6.8+incompatible // indirect
+)
+
+replace github.com/charles-d-burton/rfm69-1 => ../
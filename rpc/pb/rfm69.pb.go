@@ -0,0 +1,616 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: rfm69.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SendRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Node          uint32                 `protobuf:"varint,1,opt,name=node,proto3" json:"node,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendRequest) Reset() {
+	*x = SendRequest{}
+	mi := &file_rfm69_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRequest) ProtoMessage() {}
+
+func (x *SendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRequest.ProtoReflect.Descriptor instead.
+func (*SendRequest) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SendRequest) GetNode() uint32 {
+	if x != nil {
+		return x.Node
+	}
+	return 0
+}
+
+func (x *SendRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type SendReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendReply) Reset() {
+	*x = SendReply{}
+	mi := &file_rfm69_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendReply) ProtoMessage() {}
+
+func (x *SendReply) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendReply.ProtoReflect.Descriptor instead.
+func (*SendReply) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{1}
+}
+
+type Frame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          uint32                 `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            uint32                 `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	Kind          uint32                 `protobuf:"varint,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	Rssi          int32                  `protobuf:"zigzag32,4,opt,name=rssi,proto3" json:"rssi,omitempty"`
+	RequestAck    bool                   `protobuf:"varint,5,opt,name=request_ack,json=requestAck,proto3" json:"request_ack,omitempty"`
+	SendAck       bool                   `protobuf:"varint,6,opt,name=send_ack,json=sendAck,proto3" json:"send_ack,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,7,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_rfm69_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Frame) GetFrom() uint32 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *Frame) GetTo() uint32 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *Frame) GetKind() uint32 {
+	if x != nil {
+		return x.Kind
+	}
+	return 0
+}
+
+func (x *Frame) GetRssi() int32 {
+	if x != nil {
+		return x.Rssi
+	}
+	return 0
+}
+
+func (x *Frame) GetRequestAck() bool {
+	if x != nil {
+		return x.RequestAck
+	}
+	return false
+}
+
+func (x *Frame) GetSendAck() bool {
+	if x != nil {
+		return x.SendAck
+	}
+	return false
+}
+
+func (x *Frame) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type SubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []uint32               `protobuf:"varint,1,rep,packed,name=nodes,proto3" json:"nodes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_rfm69_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SubscribeRequest) GetNodes() []uint32 {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+type GetConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigRequest) Reset() {
+	*x = GetConfigRequest{}
+	mi := &file_rfm69_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigRequest) ProtoMessage() {}
+
+func (x *GetConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigRequest) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{4}
+}
+
+// Config is the radio configuration, SetConfig leaves the fields that
+// are not set unchanged
+type Config struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeId        *uint32                `protobuf:"varint,1,opt,name=node_id,json=nodeId,proto3,oneof" json:"node_id,omitempty"`
+	NetworkId     *uint32                `protobuf:"varint,2,opt,name=network_id,json=networkId,proto3,oneof" json:"network_id,omitempty"`
+	Frequency     *uint32                `protobuf:"varint,3,opt,name=frequency,proto3,oneof" json:"frequency,omitempty"` // Hz
+	Bitrate       *uint32                `protobuf:"varint,4,opt,name=bitrate,proto3,oneof" json:"bitrate,omitempty"`     // bits/s
+	PowerLevel    *uint32                `protobuf:"varint,5,opt,name=power_level,json=powerLevel,proto3,oneof" json:"power_level,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Config) Reset() {
+	*x = Config{}
+	mi := &file_rfm69_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Config) ProtoMessage() {}
+
+func (x *Config) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Config.ProtoReflect.Descriptor instead.
+func (*Config) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Config) GetNodeId() uint32 {
+	if x != nil && x.NodeId != nil {
+		return *x.NodeId
+	}
+	return 0
+}
+
+func (x *Config) GetNetworkId() uint32 {
+	if x != nil && x.NetworkId != nil {
+		return *x.NetworkId
+	}
+	return 0
+}
+
+func (x *Config) GetFrequency() uint32 {
+	if x != nil && x.Frequency != nil {
+		return *x.Frequency
+	}
+	return 0
+}
+
+func (x *Config) GetBitrate() uint32 {
+	if x != nil && x.Bitrate != nil {
+		return *x.Bitrate
+	}
+	return 0
+}
+
+func (x *Config) GetPowerLevel() uint32 {
+	if x != nil && x.PowerLevel != nil {
+		return *x.PowerLevel
+	}
+	return 0
+}
+
+type DumpRegistersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DumpRegistersRequest) Reset() {
+	*x = DumpRegistersRequest{}
+	mi := &file_rfm69_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DumpRegistersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DumpRegistersRequest) ProtoMessage() {}
+
+func (x *DumpRegistersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DumpRegistersRequest.ProtoReflect.Descriptor instead.
+func (*DumpRegistersRequest) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{6}
+}
+
+type Register struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Addr          uint32                 `protobuf:"varint,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	Value         uint32                 `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Register) Reset() {
+	*x = Register{}
+	mi := &file_rfm69_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Register) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Register) ProtoMessage() {}
+
+func (x *Register) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Register.ProtoReflect.Descriptor instead.
+func (*Register) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Register) GetAddr() uint32 {
+	if x != nil {
+		return x.Addr
+	}
+	return 0
+}
+
+func (x *Register) GetValue() uint32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type Registers struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Registers     []*Register            `protobuf:"bytes,1,rep,name=registers,proto3" json:"registers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Registers) Reset() {
+	*x = Registers{}
+	mi := &file_rfm69_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Registers) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Registers) ProtoMessage() {}
+
+func (x *Registers) ProtoReflect() protoreflect.Message {
+	mi := &file_rfm69_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Registers.ProtoReflect.Descriptor instead.
+func (*Registers) Descriptor() ([]byte, []int) {
+	return file_rfm69_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Registers) GetRegisters() []*Register {
+	if x != nil {
+		return x.Registers
+	}
+	return nil
+}
+
+var File_rfm69_proto protoreflect.FileDescriptor
+
+const file_rfm69_proto_rawDesc = "" +
+	"\n" +
+	"\vrfm69.proto\x12\x05rfm69\";\n" +
+	"\vSendRequest\x12\x12\n" +
+	"\x04node\x18\x01 \x01(\rR\x04node\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\"\v\n" +
+	"\tSendReply\"\xa9\x01\n" +
+	"\x05Frame\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\rR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\rR\x02to\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\rR\x04kind\x12\x12\n" +
+	"\x04rssi\x18\x04 \x01(\x11R\x04rssi\x12\x1f\n" +
+	"\vrequest_ack\x18\x05 \x01(\bR\n" +
+	"requestAck\x12\x19\n" +
+	"\bsend_ack\x18\x06 \x01(\bR\asendAck\x12\x18\n" +
+	"\apayload\x18\a \x01(\fR\apayload\"(\n" +
+	"\x10SubscribeRequest\x12\x14\n" +
+	"\x05nodes\x18\x01 \x03(\rR\x05nodes\"\x12\n" +
+	"\x10GetConfigRequest\"\xf7\x01\n" +
+	"\x06Config\x12\x1c\n" +
+	"\anode_id\x18\x01 \x01(\rH\x00R\x06nodeId\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"network_id\x18\x02 \x01(\rH\x01R\tnetworkId\x88\x01\x01\x12!\n" +
+	"\tfrequency\x18\x03 \x01(\rH\x02R\tfrequency\x88\x01\x01\x12\x1d\n" +
+	"\abitrate\x18\x04 \x01(\rH\x03R\abitrate\x88\x01\x01\x12$\n" +
+	"\vpower_level\x18\x05 \x01(\rH\x04R\n" +
+	"powerLevel\x88\x01\x01B\n" +
+	"\n" +
+	"\b_node_idB\r\n" +
+	"\v_network_idB\f\n" +
+	"\n" +
+	"_frequencyB\n" +
+	"\n" +
+	"\b_bitrateB\x0e\n" +
+	"\f_power_level\"\x16\n" +
+	"\x14DumpRegistersRequest\"4\n" +
+	"\bRegister\x12\x12\n" +
+	"\x04addr\x18\x01 \x01(\rR\x04addr\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\rR\x05value\":\n" +
+	"\tRegisters\x12-\n" +
+	"\tregisters\x18\x01 \x03(\v2\x0f.rfm69.RegisterR\tregisters2\xe9\x02\n" +
+	"\x05RFM69\x12,\n" +
+	"\x04Send\x12\x12.rfm69.SendRequest\x1a\x10.rfm69.SendReply\x123\n" +
+	"\vSendWithAck\x12\x12.rfm69.SendRequest\x1a\x10.rfm69.SendReply\x12'\n" +
+	"\x03Get\x12\x12.rfm69.SendRequest\x1a\f.rfm69.Frame\x124\n" +
+	"\tSubscribe\x12\x17.rfm69.SubscribeRequest\x1a\f.rfm69.Frame0\x01\x123\n" +
+	"\tGetConfig\x12\x17.rfm69.GetConfigRequest\x1a\r.rfm69.Config\x12)\n" +
+	"\tSetConfig\x12\r.rfm69.Config\x1a\r.rfm69.Config\x12>\n" +
+	"\rDumpRegisters\x12\x1b.rfm69.DumpRegistersRequest\x1a\x10.rfm69.RegistersB,Z*github.com/charles-d-burton/rfm69-1/rpc/pbb\x06proto3"
+
+var (
+	file_rfm69_proto_rawDescOnce sync.Once
+	file_rfm69_proto_rawDescData []byte
+)
+
+func file_rfm69_proto_rawDescGZIP() []byte {
+	file_rfm69_proto_rawDescOnce.Do(func() {
+		file_rfm69_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_rfm69_proto_rawDesc), len(file_rfm69_proto_rawDesc)))
+	})
+	return file_rfm69_proto_rawDescData
+}
+
+var file_rfm69_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_rfm69_proto_goTypes = []any{
+	(*SendRequest)(nil),          // 0: rfm69.SendRequest
+	(*SendReply)(nil),            // 1: rfm69.SendReply
+	(*Frame)(nil),                // 2: rfm69.Frame
+	(*SubscribeRequest)(nil),     // 3: rfm69.SubscribeRequest
+	(*GetConfigRequest)(nil),     // 4: rfm69.GetConfigRequest
+	(*Config)(nil),               // 5: rfm69.Config
+	(*DumpRegistersRequest)(nil), // 6: rfm69.DumpRegistersRequest
+	(*Register)(nil),             // 7: rfm69.Register
+	(*Registers)(nil),            // 8: rfm69.Registers
+}
+var file_rfm69_proto_depIdxs = []int32{
+	7, // 0: rfm69.Registers.registers:type_name -> rfm69.Register
+	0, // 1: rfm69.RFM69.Send:input_type -> rfm69.SendRequest
+	0, // 2: rfm69.RFM69.SendWithAck:input_type -> rfm69.SendRequest
+	0, // 3: rfm69.RFM69.Get:input_type -> rfm69.SendRequest
+	3, // 4: rfm69.RFM69.Subscribe:input_type -> rfm69.SubscribeRequest
+	4, // 5: rfm69.RFM69.GetConfig:input_type -> rfm69.GetConfigRequest
+	5, // 6: rfm69.RFM69.SetConfig:input_type -> rfm69.Config
+	6, // 7: rfm69.RFM69.DumpRegisters:input_type -> rfm69.DumpRegistersRequest
+	1, // 8: rfm69.RFM69.Send:output_type -> rfm69.SendReply
+	1, // 9: rfm69.RFM69.SendWithAck:output_type -> rfm69.SendReply
+	2, // 10: rfm69.RFM69.Get:output_type -> rfm69.Frame
+	2, // 11: rfm69.RFM69.Subscribe:output_type -> rfm69.Frame
+	5, // 12: rfm69.RFM69.GetConfig:output_type -> rfm69.Config
+	5, // 13: rfm69.RFM69.SetConfig:output_type -> rfm69.Config
+	8, // 14: rfm69.RFM69.DumpRegisters:output_type -> rfm69.Registers
+	8, // [8:15] is the sub-list for method output_type
+	1, // [1:8] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_rfm69_proto_init() }
+func file_rfm69_proto_init() {
+	if File_rfm69_proto != nil {
+		return
+	}
+	file_rfm69_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rfm69_proto_rawDesc), len(file_rfm69_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rfm69_proto_goTypes,
+		DependencyIndexes: file_rfm69_proto_depIdxs,
+		MessageInfos:      file_rfm69_proto_msgTypes,
+	}.Build()
+	File_rfm69_proto = out.File
+	file_rfm69_proto_goTypes = nil
+	file_rfm69_proto_depIdxs = nil
+}
@@ -0,0 +1,361 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: rfm69.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RFM69_Send_FullMethodName          = "/rfm69.RFM69/Send"
+	RFM69_SendWithAck_FullMethodName   = "/rfm69.RFM69/SendWithAck"
+	RFM69_Get_FullMethodName           = "/rfm69.RFM69/Get"
+	RFM69_Subscribe_FullMethodName     = "/rfm69.RFM69/Subscribe"
+	RFM69_GetConfig_FullMethodName     = "/rfm69.RFM69/GetConfig"
+	RFM69_SetConfig_FullMethodName     = "/rfm69.RFM69/SetConfig"
+	RFM69_DumpRegisters_FullMethodName = "/rfm69.RFM69/DumpRegisters"
+)
+
+// RFM69Client is the client API for RFM69 service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RFM69 exposes a Router to other machines
+type RFM69Client interface {
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendReply, error)
+	SendWithAck(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendReply, error)
+	// Get sends with ack and waits for the node to answer
+	Get(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*Frame, error)
+	// Subscribe streams received frames, from the given nodes only if any
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Frame], error)
+	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*Config, error)
+	SetConfig(ctx context.Context, in *Config, opts ...grpc.CallOption) (*Config, error)
+	DumpRegisters(ctx context.Context, in *DumpRegistersRequest, opts ...grpc.CallOption) (*Registers, error)
+}
+
+type rFM69Client struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRFM69Client(cc grpc.ClientConnInterface) RFM69Client {
+	return &rFM69Client{cc}
+}
+
+func (c *rFM69Client) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendReply)
+	err := c.cc.Invoke(ctx, RFM69_Send_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rFM69Client) SendWithAck(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendReply)
+	err := c.cc.Invoke(ctx, RFM69_SendWithAck_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rFM69Client) Get(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*Frame, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Frame)
+	err := c.cc.Invoke(ctx, RFM69_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rFM69Client) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Frame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RFM69_ServiceDesc.Streams[0], RFM69_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, Frame]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RFM69_SubscribeClient = grpc.ServerStreamingClient[Frame]
+
+func (c *rFM69Client) GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*Config, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Config)
+	err := c.cc.Invoke(ctx, RFM69_GetConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rFM69Client) SetConfig(ctx context.Context, in *Config, opts ...grpc.CallOption) (*Config, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Config)
+	err := c.cc.Invoke(ctx, RFM69_SetConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rFM69Client) DumpRegisters(ctx context.Context, in *DumpRegistersRequest, opts ...grpc.CallOption) (*Registers, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Registers)
+	err := c.cc.Invoke(ctx, RFM69_DumpRegisters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RFM69Server is the server API for RFM69 service.
+// All implementations must embed UnimplementedRFM69Server
+// for forward compatibility.
+//
+// RFM69 exposes a Router to other machines
+type RFM69Server interface {
+	Send(context.Context, *SendRequest) (*SendReply, error)
+	SendWithAck(context.Context, *SendRequest) (*SendReply, error)
+	// Get sends with ack and waits for the node to answer
+	Get(context.Context, *SendRequest) (*Frame, error)
+	// Subscribe streams received frames, from the given nodes only if any
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Frame]) error
+	GetConfig(context.Context, *GetConfigRequest) (*Config, error)
+	SetConfig(context.Context, *Config) (*Config, error)
+	DumpRegisters(context.Context, *DumpRegistersRequest) (*Registers, error)
+	mustEmbedUnimplementedRFM69Server()
+}
+
+// UnimplementedRFM69Server must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRFM69Server struct{}
+
+func (UnimplementedRFM69Server) Send(context.Context, *SendRequest) (*SendReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedRFM69Server) SendWithAck(context.Context, *SendRequest) (*SendReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendWithAck not implemented")
+}
+func (UnimplementedRFM69Server) Get(context.Context, *SendRequest) (*Frame, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedRFM69Server) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Frame]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedRFM69Server) GetConfig(context.Context, *GetConfigRequest) (*Config, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedRFM69Server) SetConfig(context.Context, *Config) (*Config, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetConfig not implemented")
+}
+func (UnimplementedRFM69Server) DumpRegisters(context.Context, *DumpRegistersRequest) (*Registers, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DumpRegisters not implemented")
+}
+func (UnimplementedRFM69Server) mustEmbedUnimplementedRFM69Server() {}
+func (UnimplementedRFM69Server) testEmbeddedByValue()               {}
+
+// UnsafeRFM69Server may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RFM69Server will
+// result in compilation errors.
+type UnsafeRFM69Server interface {
+	mustEmbedUnimplementedRFM69Server()
+}
+
+func RegisterRFM69Server(s grpc.ServiceRegistrar, srv RFM69Server) {
+	// If the following call pancis, it indicates UnimplementedRFM69Server was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RFM69_ServiceDesc, srv)
+}
+
+func _RFM69_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RFM69Server).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RFM69_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RFM69Server).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RFM69_SendWithAck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RFM69Server).SendWithAck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RFM69_SendWithAck_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RFM69Server).SendWithAck(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RFM69_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RFM69Server).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RFM69_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RFM69Server).Get(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RFM69_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RFM69Server).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, Frame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RFM69_SubscribeServer = grpc.ServerStreamingServer[Frame]
+
+func _RFM69_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RFM69Server).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RFM69_GetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RFM69Server).GetConfig(ctx, req.(*GetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RFM69_SetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Config)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RFM69Server).SetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RFM69_SetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RFM69Server).SetConfig(ctx, req.(*Config))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RFM69_DumpRegisters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DumpRegistersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RFM69Server).DumpRegisters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RFM69_DumpRegisters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RFM69Server).DumpRegisters(ctx, req.(*DumpRegistersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RFM69_ServiceDesc is the grpc.ServiceDesc for RFM69 service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RFM69_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rfm69.RFM69",
+	HandlerType: (*RFM69Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Send",
+			Handler:    _RFM69_Send_Handler,
+		},
+		{
+			MethodName: "SendWithAck",
+			Handler:    _RFM69_SendWithAck_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _RFM69_Get_Handler,
+		},
+		{
+			MethodName: "GetConfig",
+			Handler:    _RFM69_GetConfig_Handler,
+		},
+		{
+			MethodName: "SetConfig",
+			Handler:    _RFM69_SetConfig_Handler,
+		},
+		{
+			MethodName: "DumpRegisters",
+			Handler:    _RFM69_DumpRegisters_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _RFM69_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rfm69.proto",
+}
@@ -0,0 +1,417 @@
+// Package rpc serves a rfm69.Conn over gRPC and provides a client that is a
+// rfm69.Conn itself, so code can switch between a local and a remote radio.
+// The service is defined in pb/rfm69.proto
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pb/rfm69.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+	"github.com/charles-d-burton/rfm69-1/rpc/pb"
+)
+
+// Server implements the RFM69 service for a local radio
+type Server struct {
+	pb.UnimplementedRFM69Server
+	conn rfm69.Conn
+}
+
+// NewServer creates a server for conn, usually a *rfm69.Router
+func NewServer(conn rfm69.Conn) *Server {
+	return &Server{conn: conn}
+}
+
+// Register adds the service to g
+func (s *Server) Register(g *grpc.Server) {
+	pb.RegisterRFM69Server(g, s)
+}
+
+func node(n uint32) (byte, error) {
+	if n > 255 {
+		return 0, status.Errorf(codes.InvalidArgument, "node %d out of range", n)
+	}
+	return byte(n), nil
+}
+
+// Send implements pb.RFM69Server
+func (s *Server) Send(ctx context.Context, req *pb.SendRequest) (*pb.SendReply, error) {
+	n, err := node(req.Node)
+	if err != nil {
+		return nil, err
+	}
+	if cs, ok := s.conn.(rfm69.ContextSender); ok {
+		err = cs.SendContext(ctx, n, req.Payload)
+	} else {
+		err = s.conn.Send(n, req.Payload)
+	}
+	if err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	return &pb.SendReply{}, nil
+}
+
+// SendWithAck implements pb.RFM69Server
+func (s *Server) SendWithAck(ctx context.Context, req *pb.SendRequest) (*pb.SendReply, error) {
+	n, err := node(req.Node)
+	if err != nil {
+		return nil, err
+	}
+	if cs, ok := s.conn.(rfm69.ContextSender); ok {
+		err = cs.SendWithAckContext(ctx, n, req.Payload)
+	} else {
+		err = s.conn.SendWithAck(n, req.Payload)
+	}
+	if err != nil {
+		return nil, statusError(err, codes.DeadlineExceeded)
+	}
+	return &pb.SendReply{}, nil
+}
+
+// Get implements pb.RFM69Server
+func (s *Server) Get(ctx context.Context, req *pb.SendRequest) (*pb.Frame, error) {
+	n, err := node(req.Node)
+	if err != nil {
+		return nil, err
+	}
+	var d rfm69.Data
+	if cs, ok := s.conn.(rfm69.ContextSender); ok {
+		d, err = cs.GetContext(ctx, n, req.Payload)
+	} else {
+		d, err = s.conn.Get(n, req.Payload)
+	}
+	if err != nil {
+		return nil, statusError(err, codes.DeadlineExceeded)
+	}
+	return toFrame(d), nil
+}
+
+// errorDomain qualifies the reasons of the sentinels in status details
+const errorDomain = "rfm69"
+
+// sentinels are the package errors sent with their own code and a reason,
+// the client turns the reason back into the error so errors.Is works
+// across the connection
+var sentinels = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{rfm69.ErrPayloadSize, codes.InvalidArgument, "PAYLOAD_SIZE"},
+	{rfm69.ErrKeyLength, codes.InvalidArgument, "KEY_LENGTH"},
+	{rfm69.ErrClosed, codes.Unavailable, "CLOSED"},
+	{rfm69.ErrUnsupported, codes.Unimplemented, "UNSUPPORTED"},
+	{rfm69.ErrNoRoute, codes.NotFound, "NO_ROUTE"},
+}
+
+// statusError converts err to a status with code, or with the code and
+// reason of the sentinel it wraps
+func statusError(err error, code codes.Code) error {
+	for _, s := range sentinels {
+		if !errors.Is(err, s.err) {
+			continue
+		}
+		st, derr := status.New(s.code, err.Error()).WithDetails(&errdetails.ErrorInfo{Reason: s.reason, Domain: errorDomain})
+		if derr != nil {
+			return status.Error(s.code, err.Error())
+		}
+		return st.Err()
+	}
+	return status.Error(code, err.Error())
+}
+
+// Subscribe implements pb.RFM69Server
+func (s *Server) Subscribe(req *pb.SubscribeRequest, stream pb.RFM69_SubscribeServer) error {
+	var nodes []byte
+	for _, v := range req.Nodes {
+		n, err := node(v)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, n)
+	}
+	frames, err := s.conn.Listen(stream.Context(), nodes...)
+	if err != nil {
+		return statusError(err, codes.Unavailable)
+	}
+	for d := range frames {
+		err = stream.Send(toFrame(d))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetConfig implements pb.RFM69Server
+func (s *Server) GetConfig(ctx context.Context, req *pb.GetConfigRequest) (*pb.Config, error) {
+	c, err := s.conn.Config()
+	if err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	return toConfig(c), nil
+}
+
+// SetConfig implements pb.RFM69Server, fields not set in req keep their
+// current value. It returns the resulting configuration
+func (s *Server) SetConfig(ctx context.Context, req *pb.Config) (*pb.Config, error) {
+	cur, err := s.conn.Config()
+	if err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	c, err := applyConfig(cur, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = s.conn.SetConfig(c)
+	if err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	return s.GetConfig(ctx, nil)
+}
+
+// DumpRegisters implements pb.RFM69Server
+func (s *Server) DumpRegisters(ctx context.Context, req *pb.DumpRegistersRequest) (*pb.Registers, error) {
+	regs, err := s.conn.Registers()
+	if err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	res := &pb.Registers{}
+	for _, r := range regs {
+		res.Registers = append(res.Registers, &pb.Register{Addr: uint32(r.Addr), Value: uint32(r.Value)})
+	}
+	return res, nil
+}
+
+func toFrame(d rfm69.Data) *pb.Frame {
+	return &pb.Frame{
+		From:       uint32(d.FromAddress),
+		To:         uint32(d.ToAddress),
+		Kind:       uint32(d.Kind),
+		Rssi:       int32(d.Rssi),
+		RequestAck: d.RequestAck,
+		SendAck:    d.SendAck,
+		Payload:    d.Data,
+	}
+}
+
+func fromFrame(f *pb.Frame) rfm69.Data {
+	return rfm69.Data{
+		FromAddress: byte(f.From),
+		ToAddress:   byte(f.To),
+		Kind:        rfm69.Kind(f.Kind),
+		Rssi:        int(f.Rssi),
+		RequestAck:  f.RequestAck,
+		SendAck:     f.SendAck,
+		Data:        f.Payload,
+	}
+}
+
+// toConfig converts c, leaving out a zero frequency and bitrate as
+// RadioConfig means unchanged by them
+func toConfig(c rfm69.RadioConfig) *pb.Config {
+	pc := &pb.Config{
+		NodeId:     proto.Uint32(uint32(c.NodeID)),
+		NetworkId:  proto.Uint32(uint32(c.NetworkID)),
+		PowerLevel: proto.Uint32(uint32(c.PowerLevel)),
+	}
+	if c.Frequency != 0 {
+		pc.Frequency = proto.Uint32(c.Frequency)
+	}
+	if c.Bitrate != 0 {
+		pc.Bitrate = proto.Uint32(c.Bitrate)
+	}
+	return pc
+}
+
+// applyConfig returns cfg with the fields set in c changed
+func applyConfig(cfg rfm69.RadioConfig, c *pb.Config) (rfm69.RadioConfig, error) {
+	if c.NodeId != nil && *c.NodeId > 255 || c.NetworkId != nil && *c.NetworkId > 255 || c.PowerLevel != nil && *c.PowerLevel > 31 {
+		return rfm69.RadioConfig{}, fmt.Errorf("config out of range: node %d network %d power %d", c.GetNodeId(), c.GetNetworkId(), c.GetPowerLevel())
+	}
+	if c.NodeId != nil {
+		cfg.NodeID = byte(*c.NodeId)
+	}
+	if c.NetworkId != nil {
+		cfg.NetworkID = byte(*c.NetworkId)
+	}
+	if c.Frequency != nil {
+		cfg.Frequency = *c.Frequency
+	}
+	if c.Bitrate != nil {
+		cfg.Bitrate = *c.Bitrate
+	}
+	if c.PowerLevel != nil {
+		cfg.PowerLevel = byte(*c.PowerLevel)
+	}
+	return cfg, nil
+}
+
+// Client is a rfm69.Conn to a radio served by Server
+type Client struct {
+	cc     *grpc.ClientConn
+	client pb.RFM69Client
+}
+
+var (
+	_ rfm69.Conn          = (*Client)(nil)
+	_ rfm69.ContextSender = (*Client)(nil)
+)
+
+// Dial connects to a server, e.g. Dial("pi:6969",
+// grpc.WithTransportCredentials(insecure.NewCredentials()))
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	cc, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cc: cc, client: pb.NewRFM69Client(cc)}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.cc.Close()
+}
+
+// Send implements rfm69.Conn
+func (c *Client) Send(node byte, payload []byte) error {
+	return c.SendContext(context.Background(), node, payload)
+}
+
+// SendContext implements rfm69.ContextSender, the server gives up when
+// ctx is done
+func (c *Client) SendContext(ctx context.Context, node byte, payload []byte) error {
+	_, err := c.client.Send(ctx, &pb.SendRequest{Node: uint32(node), Payload: payload})
+	return clientError(err)
+}
+
+// SendWithAck implements rfm69.Conn
+func (c *Client) SendWithAck(node byte, payload []byte) error {
+	return c.SendWithAckContext(context.Background(), node, payload)
+}
+
+// SendWithAckContext implements rfm69.ContextSender
+func (c *Client) SendWithAckContext(ctx context.Context, node byte, payload []byte) error {
+	_, err := c.client.SendWithAck(ctx, &pb.SendRequest{Node: uint32(node), Payload: payload})
+	return clientError(err)
+}
+
+// Get implements rfm69.Conn
+func (c *Client) Get(node byte, payload []byte) (rfm69.Data, error) {
+	return c.GetContext(context.Background(), node, payload)
+}
+
+// GetContext implements rfm69.ContextSender
+func (c *Client) GetContext(ctx context.Context, node byte, payload []byte) (rfm69.Data, error) {
+	f, err := c.client.Get(ctx, &pb.SendRequest{Node: uint32(node), Payload: payload})
+	if err != nil {
+		return rfm69.Data{}, clientError(err)
+	}
+	return fromFrame(f), nil
+}
+
+// Listen implements rfm69.Conn, the channel is also closed when the
+// stream breaks
+func (c *Client) Listen(ctx context.Context, nodes ...byte) (<-chan rfm69.Data, error) {
+	req := &pb.SubscribeRequest{}
+	for _, n := range nodes {
+		req.Nodes = append(req.Nodes, uint32(n))
+	}
+	stream, err := c.client.Subscribe(ctx, req)
+	if err != nil {
+		return nil, clientError(err)
+	}
+	frames := make(chan rfm69.Data)
+	go func() {
+		defer close(frames)
+		for {
+			f, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case frames <- fromFrame(f):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return frames, nil
+}
+
+// Config implements rfm69.Conn
+func (c *Client) Config() (rfm69.RadioConfig, error) {
+	cfg, err := c.client.GetConfig(context.Background(), &pb.GetConfigRequest{})
+	if err != nil {
+		return rfm69.RadioConfig{}, clientError(err)
+	}
+	return applyConfig(rfm69.RadioConfig{}, cfg)
+}
+
+// SetConfig implements rfm69.Conn
+func (c *Client) SetConfig(cfg rfm69.RadioConfig) error {
+	_, err := c.client.SetConfig(context.Background(), toConfig(cfg))
+	return clientError(err)
+}
+
+// Registers implements rfm69.Conn
+func (c *Client) Registers() ([]rfm69.Register, error) {
+	res, err := c.client.DumpRegisters(context.Background(), &pb.DumpRegistersRequest{})
+	if err != nil {
+		return nil, clientError(err)
+	}
+	regs := make([]rfm69.Register, 0, len(res.Registers))
+	for _, r := range res.Registers {
+		regs = append(regs, rfm69.Register{Addr: byte(r.Addr), Value: byte(r.Value)})
+	}
+	return regs, nil
+}
+
+// remoteError is a status from the server carrying one of the sentinels
+type remoteError struct {
+	st  *status.Status
+	err error
+}
+
+func (e *remoteError) Error() string {
+	return e.st.Message()
+}
+
+// Unwrap returns the sentinel
+func (e *remoteError) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus keeps status.Code working on the error
+func (e *remoteError) GRPCStatus() *status.Status {
+	return e.st
+}
+
+// clientError maps a status naming one of the sentinels back to it
+func clientError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, d := range st.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != errorDomain {
+			continue
+		}
+		for _, s := range sentinels {
+			if s.reason == info.Reason {
+				return &remoteError{st: st, err: s.err}
+			}
+		}
+	}
+	return err
+}
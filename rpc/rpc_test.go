@@ -0,0 +1,262 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	rfm69 "github.com/charles-d-burton/rfm69-1"
+	"github.com/charles-d-burton/rfm69-1/rpc/pb"
+)
+
+// fakeConn is a rfm69.Conn that remembers its configuration and answers
+// every Get with the request
+type fakeConn struct {
+	mu  sync.Mutex
+	cfg rfm69.RadioConfig
+	err error // returned by the sends
+
+	frames    chan rfm69.Data // streamed to listeners if set
+	listening chan []byte     // receives the nodes of every Listen
+	regs      []rfm69.Register
+}
+
+func (f *fakeConn) Send(node byte, payload []byte) error        { return f.err }
+func (f *fakeConn) SendWithAck(node byte, payload []byte) error { return f.err }
+
+func (f *fakeConn) Get(node byte, payload []byte) (rfm69.Data, error) {
+	return rfm69.Data{FromAddress: node, Data: payload}, f.err
+}
+
+func (f *fakeConn) Listen(ctx context.Context, nodes ...byte) (<-chan rfm69.Data, error) {
+	if f.frames == nil {
+		return nil, rfm69.ErrUnsupported
+	}
+	out := make(chan rfm69.Data)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case d := <-f.frames:
+				out <- d
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	f.listening <- nodes
+	return out, nil
+}
+
+func (f *fakeConn) Config() (rfm69.RadioConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cfg, nil
+}
+
+func (f *fakeConn) SetConfig(c rfm69.RadioConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cfg = c
+	return nil
+}
+
+func (f *fakeConn) Registers() ([]rfm69.Register, error) {
+	if f.regs == nil {
+		return nil, rfm69.ErrUnsupported
+	}
+	return f.regs, nil
+}
+
+// serve runs a server for conn on a local port and dials it
+func serve(t *testing.T, conn rfm69.Conn) *Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := grpc.NewServer()
+	NewServer(conn).Register(g)
+	go g.Serve(ln)
+	c, err := Dial(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		g.Stop()
+	})
+	return c
+}
+
+var testConfig = rfm69.RadioConfig{NodeID: 1, NetworkID: 100, Frequency: 433000000, Bitrate: 55555, PowerLevel: 31}
+
+func TestSetConfigKeepsUnsetFields(t *testing.T) {
+	conn := &fakeConn{cfg: testConfig}
+	c := serve(t, conn)
+
+	res, err := c.client.SetConfig(context.Background(), &pb.Config{PowerLevel: proto.Uint32(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig
+	want.PowerLevel = 10
+	if got, _ := conn.Config(); got != want {
+		t.Fatalf("config %+v, want %+v", got, want)
+	}
+	if res.GetFrequency() != want.Frequency || res.GetPowerLevel() != 10 {
+		t.Fatalf("returned %v", res)
+	}
+
+	_, err = c.client.SetConfig(context.Background(), &pb.Config{PowerLevel: proto.Uint32(32)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("power level 32: %v", err)
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	conn := &fakeConn{cfg: testConfig}
+	c := serve(t, conn)
+
+	// a zero frequency and bitrate leave them unchanged, as locally
+	err := c.SetConfig(rfm69.RadioConfig{NodeID: 2, NetworkID: 100, PowerLevel: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig
+	want.NodeID, want.PowerLevel = 2, 5
+	if got != want {
+		t.Fatalf("config %+v, want %+v", got, want)
+	}
+}
+
+func TestClientSends(t *testing.T) {
+	conn := &fakeConn{}
+	c := serve(t, conn)
+
+	d, err := c.Get(3, []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.FromAddress != 3 || string(d.Data) != "hi" {
+		t.Fatalf("got %+v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetContext(ctx, 3, nil); status.Code(err) != codes.Canceled {
+		t.Fatalf("canceled get: %v", err)
+	}
+
+	conn.err = rfm69.ErrPayloadSize
+	if err := c.SendWithAck(3, nil); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("oversized payload: %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	conn := &fakeConn{}
+	c := serve(t, conn)
+
+	for _, sentinel := range []error{rfm69.ErrPayloadSize, rfm69.ErrClosed, rfm69.ErrNoRoute} {
+		conn.err = fmt.Errorf("node 3: %w", sentinel)
+		if err := c.Send(3, nil); !errors.Is(err, sentinel) {
+			t.Errorf("send failing with %v returned %v", sentinel, err)
+		}
+		if _, err := c.Get(3, nil); !errors.Is(err, sentinel) {
+			t.Errorf("get failing with %v returned %v", sentinel, err)
+		}
+	}
+
+	conn.err = errors.New("no ack response")
+	err := c.SendWithAck(3, nil)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("missing ack: %v", err)
+	}
+	if errors.Is(err, rfm69.ErrClosed) {
+		t.Fatal("missing ack is ErrClosed")
+	}
+
+	if _, err := c.Registers(); !errors.Is(err, rfm69.ErrUnsupported) || status.Code(err) != codes.Unimplemented {
+		t.Fatalf("registers of a radio without them: %v", err)
+	}
+}
+
+func TestClientRegisters(t *testing.T) {
+	regs := []rfm69.Register{{Addr: rfm69.REG_OPMODE, Value: 0x04}, {Addr: rfm69.REG_SYNCVALUE2, Value: 100}}
+	c := serve(t, &fakeConn{regs: regs})
+
+	got, err := c.Registers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(regs) {
+		t.Fatalf("registers %+v, want %+v", got, regs)
+	}
+	for i := range regs {
+		if got[i] != regs[i] {
+			t.Fatalf("registers %+v, want %+v", got, regs)
+		}
+	}
+}
+
+func TestClientListen(t *testing.T) {
+	conn := &fakeConn{frames: make(chan rfm69.Data), listening: make(chan []byte, 1)}
+	c := serve(t, conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames, err := c.Listen(ctx, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case nodes := <-conn.listening:
+		if string(nodes) != "\x02\x03" {
+			t.Fatalf("listening to %v", nodes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server not listening")
+	}
+
+	want := []rfm69.Data{
+		{FromAddress: 2, ToAddress: 1, Kind: 1, Rssi: -60, RequestAck: true, Data: []byte("a")},
+		{FromAddress: 3, ToAddress: 1, Rssi: -70, Data: []byte("b")},
+	}
+	for _, d := range want {
+		conn.frames <- d
+		select {
+		case got := <-frames:
+			if got.FromAddress != d.FromAddress || got.ToAddress != d.ToAddress || got.Kind != d.Kind ||
+				got.Rssi != d.Rssi || got.RequestAck != d.RequestAck || string(got.Data) != string(d.Data) {
+				t.Fatalf("streamed %+v, want %+v", got, d)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("frame not streamed")
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-frames:
+		if ok {
+			t.Fatal("frame streamed after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream open after cancel")
+	}
+}
@@ -0,0 +1,376 @@
+package rfm69
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Framer transforms payloads on their way to and from the radio. ctl is
+// the control byte of the frame (ack flags and kind), which is
+// authenticated along with the addresses
+type Framer interface {
+	// Seal wraps payload of a frame sent from -> to
+	Seal(from, to, ctl byte, payload []byte) ([]byte, error)
+	// Open verifies and unwraps the payload of a received frame. An
+	// authentic frame repeating a counter returns its payload along with
+	// ErrReplay, so a retry whose ack got lost can be acked again
+	Open(from, to, ctl byte, payload []byte) ([]byte, error)
+}
+
+// Framing errors
+var (
+	ErrReplay      = errors.New("replayed frame")
+	ErrAuth        = errors.New("frame authentication failed")
+	ErrFrameShort  = errors.New("frame too short")
+	ErrPayloadSize = errors.New("payload too large")
+)
+
+const (
+	counterLen = 4
+	tagLen     = 8
+
+	// counters are persisted this far ahead so a restart never reuses one
+	txReserve = 64
+)
+
+// CounterStore persists frame counters across restarts. A node never
+// receives from itself, so the local node ID holds the transmit counter
+type CounterStore interface {
+	Load(node byte) (uint32, error)
+	Save(node byte, counter uint32) error
+}
+
+// flusher is implemented by stores buffering saves, transmit counter
+// reservations are flushed right away
+type flusher interface {
+	Flush() error
+}
+
+// SecureFramer adds a per-sender monotonic counter and a truncated
+// HMAC-SHA256 over addresses, control byte, counter and payload. Frames that fail the check
+// or repeat a counter are rejected
+type SecureFramer struct {
+	key []byte
+	*frameCounters
+}
+
+// NewSecureFramer creates a framer, store may be nil to keep counters in
+// memory only
+func NewSecureFramer(key []byte, store CounterStore) (*SecureFramer, error) {
+	if len(key) < KeyLen {
+		return nil, ErrKeyLength
+	}
+	return &SecureFramer{
+		key:           append([]byte(nil), key...),
+		frameCounters: newFrameCounters(store),
+	}, nil
+}
+
+// Seal implements Framer
+func (s *SecureFramer) Seal(from, to, ctl byte, payload []byte) ([]byte, error) {
+	if len(payload)+counterLen+tagLen > MaxLongDataLen {
+		return nil, ErrPayloadSize
+	}
+	counter, err := s.nextCounter(from)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, counterLen, counterLen+len(payload)+tagLen)
+	binary.BigEndian.PutUint32(frame, counter)
+	frame = append(frame, payload...)
+	return append(frame, s.tag(from, to, ctl, frame)...), nil
+}
+
+// Open implements Framer
+func (s *SecureFramer) Open(from, to, ctl byte, frame []byte) ([]byte, error) {
+	if len(frame) < counterLen+tagLen {
+		return nil, ErrFrameShort
+	}
+	body, tag := frame[:len(frame)-tagLen], frame[len(frame)-tagLen:]
+	if !hmac.Equal(tag, s.tag(from, to, ctl, body)) {
+		return nil, ErrAuth
+	}
+	counter := binary.BigEndian.Uint32(body)
+	err := s.accept(from, counter)
+	if errors.Is(err, ErrReplay) {
+		return body[counterLen:], err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return body[counterLen:], nil
+}
+
+func (s *SecureFramer) tag(from, to, ctl byte, body []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte{from, to, ctl})
+	mac.Write(body)
+	return mac.Sum(nil)[:tagLen]
+}
+
+// frameCounters tracks the transmit counter and the last counter accepted
+// from every sender
+type frameCounters struct {
+	store CounterStore
+
+	// RxReserve is how far ahead of a received counter the store is
+	// written. Zero writes every counter before the frame is accepted, so
+	// nothing can be replayed after a crash. Larger values write once per
+	// RxReserve frames, but after a restart up to RxReserve frames of each
+	// sender are rejected as replays
+	RxReserve uint32
+
+	mu       sync.Mutex
+	counters map[byte]uint32
+	reserved map[byte]uint32 // counters in the store, ahead of counters
+}
+
+func newFrameCounters(store CounterStore) *frameCounters {
+	if store == nil {
+		store = NewMemoryCounterStore()
+	}
+	return &frameCounters{
+		store:    store,
+		counters: make(map[byte]uint32),
+		reserved: make(map[byte]uint32),
+	}
+}
+
+// nextCounter returns the next transmit counter, reserving a block of
+// counters in the store whenever the previous block is used up
+func (s *frameCounters) nextCounter(node byte) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter, ok := s.counters[node]
+	if !ok {
+		var err error
+		counter, err = s.store.Load(node)
+		if err != nil {
+			return 0, err
+		}
+		s.reserved[node] = counter
+	}
+	counter++
+	if counter == 0 {
+		return 0, errors.New("transmit counter exhausted, rotate the key")
+	}
+	if counter > s.reserved[node] {
+		err := s.reserve(node, counter+txReserve)
+		if err != nil {
+			return 0, err
+		}
+	}
+	s.counters[node] = counter
+	return counter, nil
+}
+
+// reserve saves counter for node and flushes the store, it must be on disk
+// before any counter up to it is used
+func (s *frameCounters) reserve(node byte, counter uint32) error {
+	err := s.store.Save(node, counter)
+	if err != nil {
+		return err
+	}
+	if f, ok := s.store.(flusher); ok {
+		err = f.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	s.reserved[node] = counter
+	return nil
+}
+
+// accept records counter as the last one seen from node unless it is not
+// newer than that
+func (s *frameCounters) accept(node byte, counter uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.counters[node]
+	if !ok {
+		var err error
+		last, err = s.store.Load(node)
+		if err != nil {
+			return err
+		}
+		s.reserved[node] = last
+	}
+	if counter <= last {
+		return ErrReplay
+	}
+	if counter > s.reserved[node] {
+		mark := counter + s.RxReserve
+		if mark < counter {
+			mark = counter
+		}
+		err := s.reserve(node, mark)
+		if err != nil {
+			return err
+		}
+	}
+	s.counters[node] = counter
+	return nil
+}
+
+// MemoryCounterStore keeps counters in memory
+type MemoryCounterStore struct {
+	mu       sync.Mutex
+	counters map[byte]uint32
+}
+
+// NewMemoryCounterStore creates an empty in memory store
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{counters: make(map[byte]uint32)}
+}
+
+// Load implements CounterStore
+func (m *MemoryCounterStore) Load(node byte) (uint32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[node], nil
+}
+
+// Save implements CounterStore
+func (m *MemoryCounterStore) Save(node byte, counter uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[node] = counter
+	return nil
+}
+
+// DefaultCounterFlush is how often a FileCounterStore writes saved
+// counters when FlushInterval is zero
+const DefaultCounterFlush = time.Second
+
+// FileCounterStore keeps counters in a JSON file. Saves are batched and
+// written atomically at most every FlushInterval, the framers flush it
+// right away when they reserve transmit counters or, see RxReserve, record
+// received ones
+type FileCounterStore struct {
+	path string
+
+	// FlushInterval is how long saves are batched, DefaultCounterFlush if
+	// zero
+	FlushInterval time.Duration
+
+	mu       sync.Mutex
+	counters map[byte]uint32
+	dirty    bool
+	timer    *time.Timer
+}
+
+// NewFileCounterStore loads the counters from path, a missing file is
+// treated as empty
+func NewFileCounterStore(path string) (*FileCounterStore, error) {
+	f := &FileCounterStore{path: path, counters: make(map[byte]uint32)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored map[string]uint32
+	err = json.Unmarshal(raw, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for k, v := range stored {
+		node, err := strconv.ParseUint(k, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad node %q", path, k)
+		}
+		f.counters[byte(node)] = v
+	}
+	return f, nil
+}
+
+// Load implements CounterStore
+func (f *FileCounterStore) Load(node byte) (uint32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counters[node], nil
+}
+
+// Save implements CounterStore, the file is written on the next flush
+func (f *FileCounterStore) Save(node byte, counter uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counters[node] = counter
+	f.dirty = true
+	if f.timer == nil {
+		interval := f.FlushInterval
+		if interval <= 0 {
+			interval = DefaultCounterFlush
+		}
+		f.timer = time.AfterFunc(interval, func() {
+			err := f.Flush()
+			if err != nil {
+				log.Printf("%s: %v", f.path, err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush writes the counters saved since the last flush
+func (f *FileCounterStore) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	if !f.dirty {
+		return nil
+	}
+	err := f.write()
+	if err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+// Close flushes pending saves
+func (f *FileCounterStore) Close() error {
+	return f.Flush()
+}
+
+// write replaces the file atomically
+func (f *FileCounterStore) write() error {
+	stored := make(map[string]uint32, len(f.counters))
+	for k, v := range f.counters {
+		stored[strconv.Itoa(int(k))] = v
+	}
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(raw)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
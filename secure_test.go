@@ -0,0 +1,305 @@
+package rfm69
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// framers returns one of every Framer, all keyed with testKey
+func framers(t *testing.T, store func() CounterStore) map[string]func() Framer {
+	return map[string]func() Framer{
+		"secure": func() Framer {
+			f, err := NewSecureFramer(testKey[:], store())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return f
+		},
+		"ccm": func() Framer {
+			f, err := NewCCMFramer(testKey, store())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return f
+		},
+	}
+}
+
+func memoryStore() CounterStore { return nil }
+
+func TestFramerRoundTrip(t *testing.T) {
+	for name, newFramer := range framers(t, memoryStore) {
+		t.Run(name, func(t *testing.T) {
+			tx, rx := newFramer(), newFramer()
+			for _, payload := range [][]byte{nil, []byte("hello")} {
+				frame, err := tx.Seal(1, 2, 0x40, payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := rx.Open(1, 2, 0x40, frame)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != string(payload) {
+					t.Fatalf("opened %q, want %q", got, payload)
+				}
+				if _, err := rx.Open(1, 2, 0x40, frame); !errors.Is(err, ErrReplay) {
+					t.Fatalf("replay: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestFramerPayloadSize(t *testing.T) {
+	for name, newFramer := range framers(t, memoryStore) {
+		t.Run(name, func(t *testing.T) {
+			tx, rx := newFramer(), newFramer()
+			// past the 64 bytes of hardware AES, up to a full long frame
+			payload := make([]byte, MaxLongDataLen-counterLen-tagLen)
+			frame, err := tx.Seal(1, 2, 0, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(frame) != MaxLongDataLen {
+				t.Fatalf("sealed to %d bytes, want %d", len(frame), MaxLongDataLen)
+			}
+			if _, err := rx.Open(1, 2, 0, frame); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.Seal(1, 2, 0, append(payload, 0)); !errors.Is(err, ErrPayloadSize) {
+				t.Fatalf("oversized payload: %v", err)
+			}
+		})
+	}
+}
+
+func TestFramerRejectsTampering(t *testing.T) {
+	for name, newFramer := range framers(t, memoryStore) {
+		t.Run(name, func(t *testing.T) {
+			tx := newFramer()
+			frame, err := tx.Seal(1, 2, 0x40, []byte("open the door"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			flipped := append([]byte(nil), frame...)
+			flipped[len(flipped)-1] ^= 1
+			for _, tc := range []struct {
+				name          string
+				from, to, ctl byte
+				frame         []byte
+			}{
+				{"sender", 3, 2, 0x40, frame},
+				{"destination", 1, 3, 0x40, frame},
+				{"ack flags", 1, 2, 0x00, frame},
+				{"kind", 1, 2, 0x40 | byte(KindMesh), frame},
+				{"payload", 1, 2, 0x40, flipped},
+				{"unsealed", 1, 2, 0x40, nil},
+			} {
+				_, err := newFramer().Open(tc.from, tc.to, tc.ctl, tc.frame)
+				if err == nil {
+					t.Errorf("%s changed, frame accepted", tc.name)
+				}
+			}
+		})
+	}
+}
+
+func TestFramedRouterRejectsUnsealed(t *testing.T) {
+	a := newAirWith(t, secured(t), 1, 2)
+	h, got := collect()
+	a.router(2).HandleDefault(h)
+
+	for _, payload := range [][]byte{nil, []byte("plain")} {
+		a.inject(2, Data{FromAddress: 1, ToAddress: 2, Data: payload})
+	}
+	nothing(t, got)
+
+	err := a.router(1).Send(2, []byte("sealed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, got); string(d.Data) != "sealed" {
+		t.Fatalf("received %q", d.Data)
+	}
+}
+
+func TestPromiscuousLeavesOthersFramesSealed(t *testing.T) {
+	a := newAirWith(t, func(r *Router) {
+		secured(t)(r)
+		r.Promiscuous = r.Radio.Address() == 3
+	}, 1, 2, 3)
+	h, got := collect()
+	a.router(3).HandleDefault(h)
+
+	err := a.router(1).Send(2, []byte("for 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := receive(t, got)
+	if d.ToAddress != 2 || len(d.Data) != len("for 2")+counterLen+tagLen {
+		t.Fatalf("sniffed %+v, want the sealed frame", d)
+	}
+	f := a.router(3).Framer.(*SecureFramer)
+	f.mu.Lock()
+	_, opened := f.counters[1]
+	f.mu.Unlock()
+	if opened {
+		t.Fatal("replay counter of node 1 advanced by a frame for node 2")
+	}
+
+	err = a.router(1).Send(3, []byte("for 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, got); string(d.Data) != "for 3" {
+		t.Fatalf("received %q", d.Data)
+	}
+}
+
+func TestFileCounterStoreBatchesSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.json")
+	s, err := NewFileCounterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FlushInterval = time.Hour
+	for c := uint32(1); c <= 100; c++ {
+		err = s.Save(7, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file written before the flush: %v", err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewFileCounterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.Load(7); c != 100 {
+		t.Fatalf("reloaded counter %d, want 100", c)
+	}
+}
+
+func TestFileCounterStoreFlushesInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.json")
+	s, err := NewFileCounterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FlushInterval = 10 * time.Millisecond
+	s.Save(7, 42)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if r, err := NewFileCounterStore(path); err == nil {
+			if c, _ := r.Load(7); c == 42 {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("counter never flushed")
+}
+
+func TestTransmitReservationSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.json")
+	open := func() CounterStore {
+		s, err := NewFileCounterStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.FlushInterval = time.Hour
+		return s
+	}
+	receivers := framers(t, memoryStore)
+	for name, newFramer := range framers(t, open) {
+		t.Run(name, func(t *testing.T) {
+			os.Remove(path)
+			rx := receivers[name]()
+			// a crash without Close, only the reservation reached the disk
+			frame, err := newFramer().Seal(1, 2, 0, []byte("a"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rx.Open(1, 2, 0, frame); err != nil {
+				t.Fatal(err)
+			}
+			frame, err = newFramer().Seal(1, 2, 0, []byte("b"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rx.Open(1, 2, 0, frame); err != nil {
+				t.Fatalf("counter reused after restart: %v", err)
+			}
+		})
+	}
+}
+
+// counters returns the counters of a framer
+func counters(f Framer) *frameCounters {
+	switch f := f.(type) {
+	case *SecureFramer:
+		return f.frameCounters
+	case *CCMFramer:
+		return f.frameCounters
+	}
+	return nil
+}
+
+func TestReceiveCounterSurvivesCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.json")
+	open := func() CounterStore {
+		s, err := NewFileCounterStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.FlushInterval = time.Hour
+		return s
+	}
+	senders := framers(t, memoryStore)
+	for name, newFramer := range framers(t, open) {
+		t.Run(name, func(t *testing.T) {
+			tx := senders[name]()
+			var frames [][]byte
+			for i := 0; i < 6; i++ {
+				frame, err := tx.Seal(1, 2, 0, []byte{byte(i)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				frames = append(frames, frame)
+			}
+
+			for _, reserve := range []uint32{0, 3} {
+				os.Remove(path)
+				rx := newFramer()
+				counters(rx).RxReserve = reserve
+				if _, err := rx.Open(1, 2, 0, frames[0]); err != nil {
+					t.Fatal(err)
+				}
+				// a crash without Close
+				rx = newFramer()
+				if _, err := rx.Open(1, 2, 0, frames[0]); !errors.Is(err, ErrReplay) {
+					t.Fatalf("reserve %d: frame replayed after a crash: %v", reserve, err)
+				}
+				// the reserved counters are lost
+				if reserve > 0 {
+					if _, err := rx.Open(1, 2, 0, frames[reserve]); !errors.Is(err, ErrReplay) {
+						t.Fatalf("reserve %d: reserved counter accepted: %v", reserve, err)
+					}
+				}
+				next := frames[1+reserve]
+				if _, err := rx.Open(1, 2, 0, next); err != nil {
+					t.Fatalf("reserve %d: %v", reserve, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package rfm69
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is how long a received sequence number is remembered.
+// It covers the retries of a request, a sender may reuse a sequence number
+// once it has sent 255 more frames to the same destination, or restarted,
+// and the window has passed
+const DefaultDedupWindow = time.Second
+
+// seqStream is the sequence of frames a sender sends to one destination:
+// a node, the broadcast address or a group
+type seqStream struct {
+	from, to byte
+}
+
+// seqCounter hands out sequence numbers, counting separately for every
+// destination
+type seqCounter struct {
+	mu   sync.Mutex
+	seqs map[byte]byte
+}
+
+func (s *seqCounter) next(to byte) byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seqs == nil {
+		s.seqs = make(map[byte]byte)
+	}
+	s.seqs[to]++
+	return s.seqs[to]
+}
+
+// dedupTable remembers recently received sequence numbers per sender and
+// destination
+type dedupTable struct {
+	mu   sync.Mutex
+	seen map[seqStream]map[byte]time.Time
+}
+
+// duplicate records seq sent from -> to and reports whether it was already
+// seen within window
+func (d *dedupTable) duplicate(from, to, seq byte, window time.Duration, now time.Time) bool {
+	if window <= 0 {
+		window = DefaultDedupWindow
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[seqStream]map[byte]time.Time)
+	}
+	stream := seqStream{from: from, to: to}
+	seqs, ok := d.seen[stream]
+	if !ok {
+		seqs = make(map[byte]time.Time)
+		d.seen[stream] = seqs
+	}
+	for s, t := range seqs {
+		if now.Sub(t) > window {
+			delete(seqs, s)
+		}
+	}
+	if _, dup := seqs[seq]; dup {
+		return true
+	}
+	seqs[seq] = now
+	return false
+}
+
+// frame builds an outgoing frame, prefixing the payload with the sequence
+// number if enabled and sealing both with the Framer, so the sequence
+// number is authenticated
+func (r *Router) frame(to byte, kind Kind, requestAck bool, payload []byte) (*Data, byte, error) {
+	d := &Data{ToAddress: to, Kind: kind, RequestAck: requestAck}
+	var seq byte
+	if r.Sequenced && sequencedKind(kind) {
+		seq = r.seqs.next(to)
+		payload = append([]byte{seq}, payload...)
+	}
+	if r.Framer != nil {
+		var err error
+		payload, err = r.Framer.Seal(r.Radio.Address(), to, ctlByte(d), payload)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(payload) > MaxLongDataLen {
+		return nil, 0, ErrPayloadSize
+	}
+	d.Data = payload
+	return d, seq, nil
+}
+
+// sequencedKind tells whether frames of kind carry a sequence number,
+// relayed frames carry the one of the frame they wrap
+func sequencedKind(kind Kind) bool {
+	return kind != KindHello && kind != KindRelay
+}
+
+// ack sends the ack for data, echoing the sequence number it acknowledges.
+// With a Framer the echo is sealed like any payload, so acks are
+// authenticated too. It transmits right away as dispatch runs on the Run
+// goroutine, which also drains r.tx
+func (r *Router) ack(data Data, seq byte, sequenced bool) {
+	a := data.ToAck()
+	if sequenced {
+		a.Data = []byte{seq}
+	}
+	if r.Framer != nil {
+		sealed, err := r.Framer.Seal(r.Radio.Address(), a.ToAddress, ctlByte(a), a.Data)
+		if err != nil {
+			log.Printf("dropping ack to %d: %v", a.ToAddress, err)
+			return
+		}
+		a.Data = sealed
+	}
+	err := r.Radio.Transmit(a)
+	if err != nil {
+		log.Println(err)
+		r.stats().LoopError(err)
+	}
+}
+
+// pendingRequest is a request waiting for its ack and then its response
+type pendingRequest struct {
+	node      byte
+	seq       byte
+	sequenced bool // acks echo seq
+	ack       bool // waiting for the ack
+	data      bool // waiting for a response once acked
+	c         chan Data
+}
+
+// acks tells whether d acknowledges the request, acks echoing an earlier
+// sequence are stale
+func (p *pendingRequest) acks(d Data) bool {
+	if !p.sequenced {
+		return len(d.Data) == 0
+	}
+	return len(d.Data) == 1 && d.Data[0] == p.seq
+}
+
+// pendingTable holds the requests in flight, several may wait on the same
+// node
+type pendingTable struct {
+	mu   sync.Mutex
+	reqs []*pendingRequest
+}
+
+func (t *pendingTable) add(node, seq byte, sequenced, ack, data bool) *pendingRequest {
+	p := &pendingRequest{node: node, seq: seq, sequenced: sequenced, ack: ack, data: data, c: make(chan Data, 2)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reqs = append(t.reqs, p)
+	return p
+}
+
+func (t *pendingTable) remove(p *pendingRequest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, q := range t.reqs {
+		if q == p {
+			t.reqs = append(t.reqs[:i], t.reqs[i+1:]...)
+			return
+		}
+	}
+}
+
+// deliver hands d to the oldest request it acks or answers, responses
+// only count once the request is acked. It returns false if no request
+// wanted d
+func (t *pendingTable) deliver(d Data) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range t.reqs {
+		if p.node != d.FromAddress {
+			continue
+		}
+		switch {
+		case d.SendAck && p.ack && p.acks(d):
+			p.ack = false
+		case !d.SendAck && !p.ack && p.data:
+			p.data = false
+		default:
+			continue
+		}
+		// each phase is delivered once, room for the ack and the response
+		p.c <- d
+		return true
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package rfm69
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupTable(t *testing.T) {
+	var d dedupTable
+	now := time.Now()
+	if d.duplicate(1, 2, 5, time.Second, now) {
+		t.Fatal("first frame reported as duplicate")
+	}
+	if !d.duplicate(1, 2, 5, time.Second, now.Add(500*time.Millisecond)) {
+		t.Fatal("repeat within the window not reported")
+	}
+	if d.duplicate(3, 2, 5, time.Second, now) {
+		t.Fatal("sequence numbers shared between senders")
+	}
+	if d.duplicate(1, BroadcastAddress, 5, time.Second, now) {
+		t.Fatal("sequence numbers shared between destinations")
+	}
+	if d.duplicate(1, 2, 5, time.Second, now.Add(2*time.Second)) {
+		t.Fatal("sequence number remembered past the window")
+	}
+}
+
+func TestDuplicatesAckedButDeliveredOnce(t *testing.T) {
+	a := newAirWith(t, func(r *Router) { r.Sequenced = true }, 1, 2)
+	h, got := collect()
+	a.router(2).Handle(1, h)
+	var mu sync.Mutex
+	var acks [][]byte
+	a.onTransmit(2, func(d Data) {
+		mu.Lock()
+		defer mu.Unlock()
+		acks = append(acks, d.Data)
+	})
+
+	// a retry whose ack got lost carries the same sequence number
+	for i := 0; i < 2; i++ {
+		a.inject(2, Data{FromAddress: 1, ToAddress: 2, RequestAck: true, Data: []byte{7, 'x'}})
+	}
+	if d := receive(t, got); string(d.Data) != "x" {
+		t.Fatalf("received %+v", d)
+	}
+	nothing(t, got)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(acks) != 2 || string(acks[0]) != "\x07" || string(acks[1]) != "\x07" {
+		t.Fatalf("acks % x, want two echoing 7", acks)
+	}
+}
+
+func TestSequencePerDestination(t *testing.T) {
+	var s seqCounter
+	for _, to := range []byte{2, 3, BroadcastAddress} {
+		if seq := s.next(to); seq != 1 {
+			t.Fatalf("first sequence number to %d is %d", to, seq)
+		}
+	}
+	if seq := s.next(2); seq != 2 {
+		t.Fatalf("second sequence number to 2 is %d", seq)
+	}
+}
+
+func TestSequenceNumberSealed(t *testing.T) {
+	a := newAirWith(t, func(r *Router) {
+		r.Sequenced = true
+		secured(t)(r)
+	}, 1, 2)
+	h, got := collect()
+	a.router(2).Handle(1, h)
+	sent := make(chan Data, 1)
+	a.onTransmit(1, func(d Data) {
+		select {
+		case sent <- d:
+		default:
+		}
+	})
+	var mu sync.Mutex
+	var acks [][]byte
+	a.onTransmit(2, func(d Data) {
+		mu.Lock()
+		defer mu.Unlock()
+		acks = append(acks, d.Data)
+	})
+	// node 1 only seals, its frame is injected below
+	a.lose(func(from byte, d Data) bool { return from == 1 })
+
+	go a.router(1).SendWithAck(2, []byte("x"))
+	frame := receive(t, sent)
+	if frame.Data[counterLen] != 1 {
+		t.Fatalf("sealed % x, want the sequence number after the counter", frame.Data)
+	}
+
+	tampered := frame
+	tampered.Data = append([]byte(nil), frame.Data...)
+	tampered.Data[counterLen] = 9
+	a.inject(2, tampered)
+	nothing(t, got)
+
+	// a retry is acked again, echoing the sealed sequence number
+	for i := 0; i < 2; i++ {
+		a.inject(2, frame)
+	}
+	if d := receive(t, got); string(d.Data) != "x" {
+		t.Fatalf("received %+v", d)
+	}
+	nothing(t, got)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(acks) != 2 || acks[0][counterLen] != 1 || acks[1][counterLen] != 1 {
+		t.Fatalf("acks % x, want two echoing 1", acks)
+	}
+}
@@ -0,0 +1,65 @@
+package rfm69
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// RxTimeoutStats counts receiver restarts caused by RX timeouts
+type RxTimeoutStats struct {
+	RxStart    uint64 // no RSSI above threshold within the RxStart timeout
+	RssiThresh uint64 // RSSI detected but no payload within the RssiThresh timeout
+}
+
+// SetRxTimeouts configures RegRxTimeout1 (RxStart, from entering RX to
+// RSSI detection) and RegRxTimeout2 (RssiThresh, from RSSI detection to
+// PayloadReady). When one expires the receive loop restarts the receiver.
+// Timeouts are rounded to 16 bit periods, zero disables a timeout
+func (r *Device) SetRxTimeouts(rxStart, rssiThresh time.Duration) error {
+	period, err := r.bitPeriod()
+	if err != nil {
+		return err
+	}
+	for i, d := range []time.Duration{rxStart, rssiThresh} {
+		units := (d + 16*period - 1) / (16 * period)
+		if units > 0xFF {
+			return fmt.Errorf("rx timeout %v exceeds %v", d, 0xFF*16*period)
+		}
+		err = r.writeReg(REG_RXTIMEOUT1+byte(i), byte(units))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RxTimeouts returns how often each RX timeout restarted the receiver
+func (r *Device) RxTimeouts() RxTimeoutStats {
+	return RxTimeoutStats{
+		RxStart:    atomic.LoadUint64(&r.rxTimeouts.RxStart),
+		RssiThresh: atomic.LoadUint64(&r.rxTimeouts.RssiThresh),
+	}
+}
+
+// bitPeriod returns the duration of a bit at the configured bit rate
+func (r *Device) bitPeriod() (time.Duration, error) {
+	br, err := r.bitrateDivider()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(uint64(br) * uint64(time.Second) / FXOSC), nil
+}
+
+// restartRx is called when the Timeout flag is set, flags1 tells which of
+// the two timeouts expired
+func (r *Device) restartRx(flags1 byte) error {
+	if flags1&RF_IRQFLAGS1_RSSI != 0 {
+		atomic.AddUint64(&r.rxTimeouts.RssiThresh, 1)
+	} else {
+		atomic.AddUint64(&r.rxTimeouts.RxStart, 1)
+	}
+	r.syncSeen = false
+	r.feiStarted = false
+	return r.readWriteReg(REG_PACKETCONFIG2, 0xFB, RF_PACKET2_RXRESTART)
+}
@@ -0,0 +1,47 @@
+package rfm69
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetRxTimeouts(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+	err := d.SetBitrate(4800) // 16 bits take 3.33ms
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SetRxTimeouts(5*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.written(REG_RXTIMEOUT1); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("RxStart timeout % x, want 02", got)
+	}
+	if got := f.written(REG_RXTIMEOUT2); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("RssiThresh timeout % x, want 00", got)
+	}
+	if err := d.SetRxTimeouts(time.Second, 0); err == nil {
+		t.Fatal("timeout past 255 units accepted")
+	}
+}
+
+func TestRxTimeoutRestartsReceiver(t *testing.T) {
+	d, f := newTestDevice(t, nil)
+
+	for i, flags := range []byte{RF_IRQFLAGS1_TIMEOUT, RF_IRQFLAGS1_TIMEOUT | RF_IRQFLAGS1_RSSI} {
+		restarts := len(f.written(REG_PACKETCONFIG2))
+		f.raise(REG_IRQFLAGS1, flags)
+		deadline := time.Now().Add(time.Second)
+		for len(f.written(REG_PACKETCONFIG2)) == restarts && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if len(f.written(REG_PACKETCONFIG2)) == restarts {
+			t.Fatalf("timeout %d never restarted the receiver", i)
+		}
+	}
+	// each timeout is counted before the restart written above
+	if got := d.RxTimeouts(); got != (RxTimeoutStats{RxStart: 1, RssiThresh: 1}) {
+		t.Fatalf("counted %+v, one of each", got)
+	}
+}
@@ -0,0 +1,38 @@
+package rfm69
+
+import "context"
+
+// Tracer traces Send, SendWithAck and Get, see package otel for an
+// OpenTelemetry adapter
+type Tracer interface {
+	// Start begins the span of an operation ("send", "send_with_ack" or
+	// "get") to node with a payload of size bytes
+	Start(ctx context.Context, op string, node byte, size int) (context.Context, Span)
+}
+
+// Span is a traced operation
+type Span interface {
+	// Attempt is called before every transmission, starting at 1
+	Attempt(n int)
+	// Ack is called when the ack arrived
+	Ack(rssi int)
+	// Data is called when the response of a Get arrived
+	Data(rssi, size int)
+	// End finishes the span, err is the result of the operation
+	End(err error)
+}
+
+type nopSpan struct{}
+
+func (nopSpan) Attempt(int)   {}
+func (nopSpan) Ack(int)       {}
+func (nopSpan) Data(int, int) {}
+func (nopSpan) End(error)     {}
+
+// startSpan starts a span with the router's tracer, if any
+func (r *Router) startSpan(ctx context.Context, op string, node byte, size int) (context.Context, Span) {
+	if r.Tracer == nil {
+		return ctx, nopSpan{}
+	}
+	return r.Tracer.Start(ctx, op, node, size)
+}
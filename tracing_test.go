@@ -0,0 +1,69 @@
+package rfm69
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// traceLog is a Tracer recording every call as a line
+type traceLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *traceLog) add(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *traceLog) Start(ctx context.Context, op string, node byte, size int) (context.Context, Span) {
+	l.add("start %s %d %d", op, node, size)
+	return ctx, l
+}
+
+func (l *traceLog) Attempt(n int)       { l.add("attempt %d", n) }
+func (l *traceLog) Ack(rssi int)        { l.add("ack") }
+func (l *traceLog) Data(rssi, size int) { l.add("data %d", size) }
+func (l *traceLog) End(err error)       { l.add("end %v", err) }
+func (l *traceLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprint(l.lines)
+}
+
+func TestTracerSpans(t *testing.T) {
+	l := new(traceLog)
+	a := newAirWith(t, func(r *Router) {
+		if r.Radio.Address() == 1 {
+			r.Tracer = l
+		}
+	}, 1, 2)
+	a.router(2).Handle(1, func(d Data) {
+		go a.router(2).Send(1, []byte("pong"))
+	})
+
+	_, err := a.router(1).Get(2, []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[start get 2 4 attempt 1 ack data 4 end <nil>]"
+	if got := l.String(); got != want {
+		t.Fatalf("traced %s, want %s", got, want)
+	}
+}
+
+func TestTracerFailedSend(t *testing.T) {
+	l := new(traceLog)
+	a := newAirWith(t, func(r *Router) { r.Tracer = l }, 1)
+	err := a.router(1).SendWithAck(2, []byte("x"))
+	if err == nil {
+		t.Fatal("send to a missing node acked")
+	}
+	want := "[start send_with_ack 2 1 attempt 1 attempt 2 attempt 3 end no ack response]"
+	if got := l.String(); got != want {
+		t.Fatalf("traced %s, want %s", got, want)
+	}
+}
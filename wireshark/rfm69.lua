@@ -0,0 +1,83 @@
+-- Wireshark dissector for RFM69 captures written by rfm69.PcapWriter
+--
+-- Copy to the personal plugins folder (Help > About > Folders) or run
+-- wireshark -X lua_script:rfm69.lua capture.pcapng
+
+local rfm69 = Proto("rfm69", "RFM69 capture")
+
+local f = rfm69.fields
+f.version   = ProtoField.uint8("rfm69.version", "Header version")
+f.flags     = ProtoField.uint8("rfm69.flags", "Flags", base.HEX)
+f.crc_ok    = ProtoField.bool("rfm69.crc_ok", "CRC ok", 8, nil, 0x01)
+f.fei_valid = ProtoField.bool("rfm69.fei_valid", "FEI valid", 8, nil, 0x02)
+f.rssi      = ProtoField.int8("rfm69.rssi", "RSSI (dBm)")
+f.frequency = ProtoField.uint32("rfm69.frequency", "Frequency (Hz)")
+f.fei       = ProtoField.int32("rfm69.fei", "Frequency error (Hz)")
+
+local lpl = Proto("lowpowerlab", "LowPowerLab RFM69")
+
+local kinds = { [0] = "Data", [1] = "Hello", [2] = "Group", [3] = "Mesh", [4] = "Relay" }
+
+local g = lpl.fields
+g.length   = ProtoField.uint8("lowpowerlab.length", "Length")
+g.to       = ProtoField.uint8("lowpowerlab.to", "To")
+g.from     = ProtoField.uint8("lowpowerlab.from", "From")
+g.control  = ProtoField.uint8("lowpowerlab.control", "Control", base.HEX)
+g.send_ack = ProtoField.bool("lowpowerlab.send_ack", "Ack", 8, nil, 0x80)
+g.req_ack  = ProtoField.bool("lowpowerlab.request_ack", "Ack requested", 8, nil, 0x40)
+g.kind     = ProtoField.uint8("lowpowerlab.kind", "Kind", base.DEC, kinds, 0x0F)
+g.payload  = ProtoField.bytes("lowpowerlab.payload", "Payload")
+
+local HEADER_LEN = 12
+
+function rfm69.dissector(buf, pinfo, tree)
+    if buf:len() < HEADER_LEN then
+        return 0
+    end
+    pinfo.cols.protocol = "RFM69"
+    local t = tree:add(rfm69, buf(0, HEADER_LEN))
+    t:add(f.version, buf(0, 1))
+    local flags = t:add(f.flags, buf(1, 1))
+    flags:add(f.crc_ok, buf(1, 1))
+    flags:add(f.fei_valid, buf(1, 1))
+    t:add(f.rssi, buf(2, 1))
+    t:add(f.frequency, buf(4, 4))
+    t:add(f.fei, buf(8, 4))
+
+    local frame = buf(HEADER_LEN):tvb()
+    lpl.dissector:call(frame, pinfo, tree)
+    return buf:len()
+end
+
+function lpl.dissector(buf, pinfo, tree)
+    if buf:len() < 4 then
+        return 0
+    end
+    pinfo.cols.protocol = "LowPowerLab"
+    local t = tree:add(lpl, buf())
+    t:add(g.length, buf(0, 1))
+    t:add(g.to, buf(1, 1))
+    t:add(g.from, buf(2, 1))
+    local ctl = t:add(g.control, buf(3, 1))
+    ctl:add(g.send_ack, buf(3, 1))
+    ctl:add(g.req_ack, buf(3, 1))
+    ctl:add(g.kind, buf(3, 1))
+    if buf:len() > 4 then
+        t:add(g.payload, buf(4))
+    end
+
+    local to, from, c = buf(1, 1):uint(), buf(2, 1):uint(), buf(3, 1):uint()
+    local kind = kinds[bit.band(c, 0x0F)] or "Unknown"
+    local info = string.format("%d → %d %s", from, to, kind)
+    if bit.band(c, 0x80) ~= 0 then
+        info = info .. " ACK"
+    elseif bit.band(c, 0x40) ~= 0 then
+        info = info .. " (ack requested)"
+    end
+    pinfo.cols.info = info
+    pinfo.cols.src = tostring(from)
+    pinfo.cols.dst = tostring(to)
+    return buf:len()
+end
+
+DissectorTable.get("wtap_encap"):add(wtap.USER0, rfm69)